@@ -0,0 +1,122 @@
+package blobs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"notes-app/database"
+	"notes-app/models"
+	"notes-app/storage"
+	"notes-app/utils"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// keyPrefix is the storage prefix of content-addressed blobs
+const keyPrefix = "blobs/"
+
+// Key returns the storage key of the blob with the given SHA-256 hex hash
+func Key(hash string) string {
+	return keyPrefix + hash[:2] + "/" + hash
+}
+
+// HashFromKey returns the hash of a blob key; ok is false for keys stored
+// before uploads were content-addressed
+func HashFromKey(key string) (hash string, ok bool) {
+	if !strings.HasPrefix(key, keyPrefix) {
+		return "", false
+	}
+	hash = key[strings.LastIndexByte(key, '/')+1:]
+	return hash, len(hash) == sha256.Size*2
+}
+
+// Acquire takes a reference to the blob with the given hash, storing the
+// content returned by open only if no identical blob exists yet. It returns
+// the blob's storage key.
+func Acquire(hash string, size int64, mimeType string, open func() (io.ReadCloser, error)) (string, error) {
+	key := Key(hash)
+
+	// The row lock serializes Acquire and Release of the same blob, so the
+	// stored object can't be deleted while a new reference is being taken
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var blob models.Blob
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("hash = ?", hash).First(&blob).Error
+		if err == nil {
+			return tx.Model(&blob).Update("ref_count", gorm.Expr("ref_count + 1")).Error
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		r, err := open()
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+
+		if err := storage.Store.Put(key, r, size, mimeType); err != nil {
+			return err
+		}
+
+		// A concurrent upload of the same new content may have inserted the row meanwhile
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "hash"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{"ref_count": gorm.Expr("blobs.ref_count + 1")}),
+		}).Create(&models.Blob{Hash: hash, Size: size, MimeType: mimeType, RefCount: 1}).Error
+	})
+	if err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// AcquireBytes is Acquire for content held in memory
+func AcquireBytes(data []byte, mimeType string) (string, error) {
+	sum := sha256.Sum256(data)
+	return Acquire(hex.EncodeToString(sum[:]), int64(len(data)), mimeType, func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	})
+}
+
+// Release drops a reference to a blob and deletes it once it is no longer
+// referenced. Keys from before content addressing are deleted directly.
+func Release(key string) error {
+	if key == "" {
+		return nil
+	}
+
+	hash, ok := HashFromKey(key)
+	if !ok {
+		return storage.Store.Delete(key)
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		var blob models.Blob
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("hash = ?", hash).First(&blob).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if blob.RefCount > 1 {
+			return tx.Model(&blob).Update("ref_count", gorm.Expr("ref_count - 1")).Error
+		}
+
+		if err := tx.Delete(&blob).Error; err != nil {
+			return err
+		}
+		// Deleting while holding the lock keeps a concurrent Acquire from
+		// reusing the object; a failure only leaves an orphan behind
+		if err := storage.Store.Delete(key); err != nil {
+			utils.LogError(fmt.Sprintf("Failed to delete blob %s: %v", key, err))
+		}
+		return nil
+	})
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"notes-app/blobs"
+	"notes-app/config"
+	"notes-app/database"
+	"notes-app/models"
+	"notes-app/storage"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// runCleanupUploads implements the cleanup-uploads subcommand. It compares
+// the objects in upload storage with the keys referenced by the database,
+// reports orphaned and missing files, and optionally deletes old orphans.
+func runCleanupUploads(cfg *config.Config, args []string) int {
+	fs := flag.NewFlagSet("cleanup-uploads", flag.ExitOnError)
+	deleteOrphans := fs.Bool("delete", false, "delete orphaned files older than -older-than")
+	olderThan := fs.Duration("older-than", 24*time.Hour, "only delete orphans last modified longer ago than this")
+	dryRun := fs.Bool("dry-run", false, "report what -delete would remove without deleting anything")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s cleanup-uploads [-delete] [-older-than 24h] [-dry-run]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	database.ConnectDB(cfg.DatabaseURL)
+	defer database.CloseDB()
+
+	if err := storage.Setup(cfg); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to initialize storage:", err)
+		return 1
+	}
+
+	referenced, err := referencedKeys()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to load referenced files:", err)
+		return 1
+	}
+
+	uploading, err := activeUploads()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to load upload sessions:", err)
+		return 1
+	}
+
+	// Walk storage, collecting orphans and ticking off referenced keys;
+	// chunks of uploads still in progress are neither
+	var orphans []storage.Object
+	found := make(map[string]bool, len(referenced))
+	err = storage.Store.List("", func(obj storage.Object) error {
+		if uploading[uploadIDFromChunkKey(obj.Key)] {
+			return nil
+		}
+		if referenced[obj.Key] {
+			found[obj.Key] = true
+		} else {
+			orphans = append(orphans, obj)
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to list storage:", err)
+		return 1
+	}
+
+	var missing []string
+	for key := range referenced {
+		if !found[key] {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+	sort.Slice(orphans, func(i, j int) bool { return orphans[i].Key < orphans[j].Key })
+
+	cutoff := time.Now().Add(-*olderThan)
+	var orphanBytes int64
+	deleted, failed := 0, 0
+	for _, obj := range orphans {
+		orphanBytes += obj.Size
+		action := "orphan "
+		if (*deleteOrphans || *dryRun) && obj.ModTime.Before(cutoff) {
+			switch {
+			case *dryRun:
+				action = "would delete"
+			case storage.Store.Delete(obj.Key) != nil:
+				action = "delete failed"
+				failed++
+			default:
+				action = "deleted"
+				deleted++
+			}
+		}
+		fmt.Printf("%-13s %s\t%d bytes\t%s\n", action, obj.Key, obj.Size, obj.ModTime.Format(time.RFC3339))
+	}
+	for _, key := range missing {
+		fmt.Printf("%-13s %s\n", "missing", key)
+	}
+
+	fmt.Printf("\n%d referenced files, %d orphans (%d bytes), %d missing", len(referenced), len(orphans), orphanBytes, len(missing))
+	if *deleteOrphans && !*dryRun {
+		fmt.Printf(", %d deleted, %d failed", deleted, failed)
+	}
+	fmt.Println()
+
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// referencedKeys returns every storage key the database points to
+func referencedKeys() (map[string]bool, error) {
+	keys := make(map[string]bool)
+	add := func(values []string) {
+		for _, v := range values {
+			if v != "" {
+				keys[v] = true
+			}
+		}
+	}
+
+	for _, column := range []string{"image_key", "image_medium_key", "image_thumbnail_key"} {
+		var values []string
+		if err := database.DB.Model(&models.Note{}).Where(column+" <> ''").Pluck(column, &values).Error; err != nil {
+			return nil, err
+		}
+		add(values)
+	}
+
+	var attachmentKeys []string
+	if err := database.DB.Model(&models.Attachment{}).Pluck("storage_key", &attachmentKeys).Error; err != nil {
+		return nil, err
+	}
+	add(attachmentKeys)
+
+	var hashes []string
+	if err := database.DB.Model(&models.Blob{}).Pluck("hash", &hashes).Error; err != nil {
+		return nil, err
+	}
+	for _, hash := range hashes {
+		keys[blobs.Key(hash)] = true
+	}
+
+	return keys, nil
+}
+
+// activeUploads returns the IDs of the resumable uploads that have a session
+func activeUploads() (map[string]bool, error) {
+	var ids []string
+	if err := database.DB.Model(&models.UploadSession{}).Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	uploads := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		uploads[id] = true
+	}
+	return uploads, nil
+}
+
+// uploadIDFromChunkKey returns the upload ID of a chunk's storage key, or ""
+// if key isn't one
+func uploadIDFromChunkKey(key string) string {
+	if !strings.HasPrefix(key, models.UploadChunkPrefix) {
+		return ""
+	}
+	id, _, _ := strings.Cut(strings.TrimPrefix(key, models.UploadChunkPrefix), ".")
+	return id
+}
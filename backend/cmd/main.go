@@ -5,9 +5,15 @@ import (
 	"log"
 	"notes-app/config"
 	"notes-app/database"
+	"notes-app/mailer"
+	"notes-app/oidc"
+	"notes-app/revocation"
 	"notes-app/routes"
+	"notes-app/scheduler"
+	"notes-app/storage"
 	"notes-app/utils"
 	"os"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -19,6 +25,11 @@ func main() {
 	// Load configuration
 	cfg := config.LoadConfig()
 
+	// Maintenance subcommands
+	if len(os.Args) > 1 && os.Args[1] == "cleanup-uploads" {
+		os.Exit(runCleanupUploads(cfg, os.Args[2:]))
+	}
+
 	// Initialize logger
 	utils.InitLogger()
 	utils.LogInfo("Starting Notes Sharing App...")
@@ -28,25 +39,54 @@ func main() {
 	defer database.CloseDB()
 
 	// Create directories for uploads and logs
-	createDirectories()
+	createDirectories(cfg)
+
+	// Initialize upload storage
+	if err := storage.Setup(cfg); err != nil {
+		log.Fatal("Failed to initialize storage: ", err)
+	}
+
+	// Initialize outgoing email
+	if err := mailer.Setup(cfg); err != nil {
+		log.Fatal("Failed to initialize mailer: ", err)
+	}
+
+	// Initialize access token revocation
+	if err := revocation.Setup(cfg); err != nil {
+		log.Fatal("Failed to initialize token revocation: ", err)
+	}
+
+	// Initialize single sign-on providers
+	if err := oidc.Setup(cfg); err != nil {
+		log.Fatal("Failed to initialize single sign-on: ", err)
+	}
+
+	// Start background jobs
+	stop := make(chan struct{})
+	defer close(stop)
+	scheduler.StartReminderScheduler(cfg.ReminderInterval, stop)
+	scheduler.StartUploadCleanup(time.Hour, stop)
+	scheduler.StartTokenCleanup(time.Hour, stop)
 
 	// Initialize Fiber app
 	app := fiber.New(fiber.Config{
 		ErrorHandler: customErrorHandler,
+		// Leave room for multipart overhead on top of the largest accepted upload
+		BodyLimit: int(cfg.MaxUploadBytes) + 1<<20,
+		// Client IPs are recorded with sessions
+		ProxyHeader: cfg.ProxyHeader,
 	})
 
 	// Middleware
 	app.Use(recover.New())
 	app.Use(logger.New())
 	app.Use(cors.New(cors.Config{
-		AllowOrigins: "*",
-		AllowHeaders: "Origin, Content-Type, Accept, Authorization",
-		AllowMethods: "GET, POST, PUT, DELETE, OPTIONS",
+		AllowOrigins:  "*",
+		AllowHeaders:  "Origin, Content-Type, Accept, Authorization, Upload-Offset",
+		AllowMethods:  "GET, POST, PUT, PATCH, DELETE, OPTIONS",
+		ExposeHeaders: "Location, Upload-Offset",
 	}))
 
-	// Serve static files (uploads)
-	app.Static("/uploads", "./uploads")
-
 	// Setup routes
 	routes.SetupRoutes(app)
 
@@ -80,8 +120,11 @@ func customErrorHandler(c *fiber.Ctx, err error) error {
 }
 
 // createDirectories creates necessary directories
-func createDirectories() {
-	dirs := []string{"./uploads", "./logs"}
+func createDirectories(cfg *config.Config) {
+	dirs := []string{"./logs"}
+	if cfg.StorageBackend == "local" {
+		dirs = append(dirs, cfg.UploadDir)
+	}
 	for _, dir := range dirs {
 		if _, err := os.Stat(dir); os.IsNotExist(err) {
 			os.MkdirAll(dir, 0755)
@@ -3,6 +3,7 @@ package database
 import (
 	"log"
 	"notes-app/models"
+	"time"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -27,17 +28,58 @@ func ConnectDB(databaseURL string) {
 	log.Println("Database connection established")
 
 	// Auto-migrate models
-	err = DB.AutoMigrate(&models.User{}, &models.Note{})
+	err = DB.AutoMigrate(
+		&models.User{},
+		&models.Note{},
+		&models.NoteLink{},
+		&models.NoteTemplate{},
+		&models.Reminder{},
+		&models.Notification{},
+		&models.Attachment{},
+		&models.Blob{},
+		&models.UploadSession{},
+		&models.RefreshToken{},
+		&models.RevokedToken{},
+		&models.Session{},
+		&models.PasswordResetToken{},
+		&models.RecoveryCode{},
+		&models.TwoFactorChallenge{},
+		&models.Passkey{},
+		&models.WebAuthnChallenge{},
+		&models.OIDCIdentity{},
+		&models.OIDCLogin{},
+		&models.PersonalAccessToken{},
+	)
 	if err != nil {
 		log.Fatal("Failed to migrate database:", err)
 	}
 
+	migrateImageURLs()
+
 	log.Println("Database migration completed")
 
 	// Seed dummy data
 	seedData()
 }
 
+// migrateImageURLs moves legacy notes.image_url values ("/uploads/<file>") into
+// notes.image_key and drops the old column
+func migrateImageURLs() {
+	if !DB.Migrator().HasColumn(&models.Note{}, "image_url") {
+		return
+	}
+
+	err := DB.Exec(`UPDATE notes SET image_key = regexp_replace(image_url, '^/uploads/', '')
+		WHERE image_url <> '' AND (image_key IS NULL OR image_key = '')`).Error
+	if err != nil {
+		log.Fatal("Failed to migrate image URLs:", err)
+	}
+
+	if err := DB.Migrator().DropColumn(&models.Note{}, "image_url"); err != nil {
+		log.Fatal("Failed to drop image_url column:", err)
+	}
+}
+
 // CloseDB closes the database connection
 func CloseDB() {
 	sqlDB, err := DB.DB()
@@ -59,16 +101,19 @@ func seedData() {
 	}
 
 	// Create dummy users
+	verifiedAt := time.Now()
 	users := []models.User{
 		{
-			Name:     "Tania",
-			Email:    "tania@gmail.com",
-			Password: "$2a$12$8fJ14OmwekyKZhbMQ52MiOUxjZ5KRW92uE/peQ4MUP/Y3HWFeLog.",
+			Name:            "Tania",
+			Email:           "tania@gmail.com",
+			EmailVerifiedAt: &verifiedAt,
+			Password:        "$2a$12$8fJ14OmwekyKZhbMQ52MiOUxjZ5KRW92uE/peQ4MUP/Y3HWFeLog.",
 		},
 		{
-			Name:     "cahya",
-			Email:    "cahya@gmail.com",
-			Password: "$2a$12$08c0F8insbCFWYTzEBcWq.qaolWtmJ2inkCVB7zd1MepSZ4W0ND4W",
+			Name:            "cahya",
+			Email:           "cahya@gmail.com",
+			EmailVerifiedAt: &verifiedAt,
+			Password:        "$2a$12$08c0F8insbCFWYTzEBcWq.qaolWtmJ2inkCVB7zd1MepSZ4W0ND4W",
 		},
 	}
 
@@ -79,22 +124,19 @@ func seedData() {
 	// Create dummy notes
 	notes := []models.Note{
 		{
-			UserID:   1,
-			Title:    "Welcome Note",
-			Content:  "This is your first note! Welcome to Notes Sharing App.",
-			ImageURL: "",
+			UserID:  1,
+			Title:   "Welcome Note",
+			Content: "This is your first note! Welcome to Notes Sharing App.",
 		},
 		{
-			UserID:   1,
-			Title:    "Shopping List",
-			Content:  "1. Milk\n2. Bread\n3. Eggs\n4. Butter",
-			ImageURL: "",
+			UserID:  1,
+			Title:   "Shopping List",
+			Content: "1. Milk\n2. Bread\n3. Eggs\n4. Butter",
 		},
 		{
-			UserID:   2,
-			Title:    "Meeting Notes",
-			Content:  "Discussed project timeline and deliverables for Q4 2024.",
-			ImageURL: "",
+			UserID:  2,
+			Title:   "Meeting Notes",
+			Content: "Discussed project timeline and deliverables for Q4 2024.",
 		},
 	}
 
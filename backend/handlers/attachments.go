@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"notes-app/blobs"
+	"notes-app/config"
+	"notes-app/database"
+	"notes-app/models"
+	"notes-app/storage"
+	"notes-app/utils"
+	"path/filepath"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// GetAttachments retrieves the attachments of a note
+func GetAttachments(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	noteID := c.Params("id")
+
+	var note models.Note
+	if err := database.DB.Where("id = ? AND user_id = ?", noteID, userID).First(&note).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Note not found",
+		})
+	}
+
+	var attachments []models.Attachment
+	if err := database.DB.Where("note_id = ?", note.ID).Order("created_at").Find(&attachments).Error; err != nil {
+		utils.LogError("Failed to get attachments: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve attachments",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"attachments": attachments,
+	})
+}
+
+// UploadAttachment attaches a file to a note
+func UploadAttachment(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	noteID := c.Params("id")
+
+	var note models.Note
+	if err := database.DB.Where("id = ? AND user_id = ?", noteID, userID).First(&note).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Note not found",
+		})
+	}
+
+	// Get uploaded file
+	file, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "No file provided",
+		})
+	}
+
+	cfg := config.LoadConfig()
+	if file.Size > cfg.MaxUploadBytes {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+			"error": fmt.Sprintf("File is too large, maximum size is %d bytes", cfg.MaxUploadBytes),
+		})
+	}
+
+	if ok, err := checkQuota(c, userID, file.Size, 0); !ok {
+		return err
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		utils.LogError("Failed to open uploaded file: " + err.Error())
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Failed to read file",
+		})
+	}
+	defer src.Close()
+
+	// Detect the MIME type from the content and check it against the allowlist
+	head := make([]byte, 512)
+	n, err := io.ReadFull(src, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		utils.LogError("Failed to read uploaded file: " + err.Error())
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Failed to read file",
+		})
+	}
+	head = head[:n]
+
+	mimeType := utils.DetectMIME(head, file.Filename)
+	if !mimeAllowed(mimeType, cfg.AttachmentMIMETypes) {
+		return c.Status(fiber.StatusUnsupportedMediaType).JSON(fiber.Map{
+			"error": fmt.Sprintf("File type %s is not allowed", mimeType),
+		})
+	}
+
+	// Hash the content, then store it unless an identical file already exists
+	hasher := sha256.New()
+	hasher.Write(head)
+	if _, err := io.Copy(hasher, src); err != nil {
+		utils.LogError("Failed to read uploaded file: " + err.Error())
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Failed to read file",
+		})
+	}
+	checksum := hex.EncodeToString(hasher.Sum(nil))
+
+	key, err := blobs.Acquire(checksum, file.Size, mimeType, func() (io.ReadCloser, error) {
+		return file.Open()
+	})
+	if err != nil {
+		utils.LogError("Failed to save attachment: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to save attachment",
+		})
+	}
+
+	attachment := models.Attachment{
+		NoteID:     note.ID,
+		UserID:     userID,
+		Filename:   sanitizeFilename(file.Filename),
+		MimeType:   mimeType,
+		Size:       file.Size,
+		StorageKey: key,
+		Checksum:   checksum,
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := reserveQuota(tx, userID, file.Size, 0); err != nil {
+			return err
+		}
+		return tx.Create(&attachment).Error
+	})
+	if errors.Is(err, errQuotaExceeded) {
+		releaseFiles(key)
+		return quotaExceeded(c, userID, file.Size)
+	}
+	if err != nil {
+		utils.LogError("Failed to create attachment: " + err.Error())
+		releaseFiles(key)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to save attachment",
+		})
+	}
+
+	utils.LogInfo(fmt.Sprintf("Attachment uploaded: ID=%d, NoteID=%d, UserID=%d", attachment.ID, note.ID, userID))
+
+	return c.Status(fiber.StatusCreated).JSON(attachment)
+}
+
+// DownloadAttachment streams an attachment's content
+func DownloadAttachment(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	var attachment models.Attachment
+	if err := database.DB.Where("id = ? AND note_id = ? AND user_id = ?", c.Params("attachmentID"), c.Params("id"), userID).
+		First(&attachment).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Attachment not found",
+		})
+	}
+
+	reader, err := storage.Store.Get(attachment.StorageKey)
+	if err != nil {
+		utils.LogError("Failed to read attachment: " + err.Error())
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Attachment file not found",
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, attachment.MimeType)
+	c.Set(fiber.HeaderContentDisposition, mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename}))
+	c.Set("X-Content-Type-Options", "nosniff")
+	return c.SendStream(reader, int(attachment.Size))
+}
+
+// DeleteAttachment removes an attachment and its file
+func DeleteAttachment(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	var attachment models.Attachment
+	if err := database.DB.Where("id = ? AND note_id = ? AND user_id = ?", c.Params("attachmentID"), c.Params("id"), userID).
+		First(&attachment).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Attachment not found",
+		})
+	}
+
+	if err := database.DB.Delete(&attachment).Error; err != nil {
+		utils.LogError("Failed to delete attachment: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete attachment",
+		})
+	}
+
+	releaseFiles(attachment.StorageKey)
+
+	utils.LogInfo(fmt.Sprintf("Attachment deleted: ID=%d, UserID=%d", attachment.ID, userID))
+
+	return c.JSON(fiber.Map{
+		"message": "Attachment deleted successfully",
+	})
+}
+
+// mimeAllowed reports whether mimeType is in the allowlist
+func mimeAllowed(mimeType string, allowlist []string) bool {
+	for _, allowed := range allowlist {
+		if strings.EqualFold(allowed, mimeType) {
+			return true
+		}
+	}
+	return false
+}
+
+// sanitizeFilename keeps the base name of an uploaded file and replaces
+// characters that are unsafe in download file names
+func sanitizeFilename(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7F || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if name == "" || name == "." || name == ".." {
+		return "file"
+	}
+	return name
+}
+
+// releaseFiles drops references to stored files, logging failures.
+// It runs after the database no longer references the files, so a failure
+// only leaves an orphan behind.
+func releaseFiles(keys ...string) {
+	for _, key := range keys {
+		if err := blobs.Release(key); err != nil {
+			utils.LogError(fmt.Sprintf("Failed to release stored file %s: %v", key, err))
+		}
+	}
+}
+
+// mapValues returns the values of m
+func mapValues(m map[string]string) []string {
+	values := make([]string, 0, len(m))
+	for _, v := range m {
+		values = append(values, v)
+	}
+	return values
+}
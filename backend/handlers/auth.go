@@ -4,6 +4,7 @@ import (
 	"notes-app/database"
 	"notes-app/models"
 	"notes-app/utils"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -27,6 +28,14 @@ func Register(c *fiber.Ctx) error {
 		})
 	}
 
+	email, ok := utils.NormalizeEmail(req.Email)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid email address",
+		})
+	}
+	req.Email = email
+
 	if len(req.Password) < 6 {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Password must be at least 6 characters",
@@ -35,7 +44,7 @@ func Register(c *fiber.Ctx) error {
 
 	// Check if user already exists
 	var existingUser models.User
-	if err := database.DB.Where("email = ?", req.Email).First(&existingUser).Error; err == nil {
+	if err := database.DB.Where("LOWER(email) = ?", req.Email).First(&existingUser).Error; err == nil {
 		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
 			"error": "User with this email already exists",
 		})
@@ -65,8 +74,13 @@ func Register(c *fiber.Ctx) error {
 
 	utils.LogInfo("User registered: " + user.Email)
 
+	// The account works right away, but some features wait for the address to be verified
+	if err := sendVerificationEmail(&user); err != nil {
+		utils.LogError("Failed to send verification email: " + err.Error())
+	}
+
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-		"message": "User registered successfully",
+		"message": "User registered successfully, check your email to verify your address",
 		"user":    user,
 	})
 }
@@ -90,9 +104,11 @@ func Login(c *fiber.Ctx) error {
 		})
 	}
 
-	// Find user by email
+	// Find user by email; addresses are stored lower-cased, but older
+	// accounts may not be
 	var user models.User
-	if err := database.DB.Where("email = ?", req.Email).First(&user).Error; err != nil {
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	if err := database.DB.Where("LOWER(email) = ?", email).First(&user).Error; err != nil {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error": "Invalid email or password",
 		})
@@ -105,20 +121,7 @@ func Login(c *fiber.Ctx) error {
 		})
 	}
 
-	// Generate JWT token
-	token, err := utils.GenerateToken(user.ID, user.Email)
-	if err != nil {
-		utils.LogError("Failed to generate token: " + err.Error())
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to generate token",
-		})
-	}
-
-	utils.LogInfo("User logged in: " + user.Email)
-
-	return c.JSON(fiber.Map{
-		"message": "Login successful",
-		"token":   token,
-		"user":    user,
-	})
-}
+	// With 2FA enabled the password alone only earns a challenge, completed
+	// at /api/auth/2fa/verify with a code from the authenticator app
+	return finishLogin(c, &user, "User logged in: ")
+}
\ No newline at end of file
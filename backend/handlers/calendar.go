@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"fmt"
+	"notes-app/database"
+	"notes-app/models"
+	"notes-app/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// RegenerateCalendarToken creates a new calendar feed URL, revoking the previous one
+func RegenerateCalendarToken(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	token, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		utils.LogError("Failed to generate calendar token: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to generate calendar token",
+		})
+	}
+
+	hash := utils.HashToken(token)
+	if err := database.DB.Model(&models.User{}).Where("id = ?", userID).Update("calendar_token_hash", hash).Error; err != nil {
+		utils.LogError("Failed to save calendar token: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to generate calendar token",
+		})
+	}
+
+	utils.LogInfo(fmt.Sprintf("Calendar token regenerated: UserID=%d", userID))
+
+	// The token is only stored hashed, so this is the only time the URL can be shown
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"url": fmt.Sprintf("%s/api/calendar/%s.ics", c.BaseURL(), token),
+	})
+}
+
+// DeleteCalendarToken disables the calendar feed
+func DeleteCalendarToken(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	if err := database.DB.Model(&models.User{}).Where("id = ?", userID).Update("calendar_token_hash", nil).Error; err != nil {
+		utils.LogError("Failed to delete calendar token: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete calendar token",
+		})
+	}
+
+	utils.LogInfo(fmt.Sprintf("Calendar token deleted: UserID=%d", userID))
+
+	return c.JSON(fiber.Map{
+		"message": "Calendar feed disabled",
+	})
+}
+
+// GetCalendarFeed serves the iCalendar feed identified by a secret token.
+// Use ?type=todo to get VTODO components instead of VEVENT.
+func GetCalendarFeed(c *fiber.Ctx) error {
+	var user models.User
+	if err := database.DB.Where("calendar_token_hash = ?", utils.HashToken(c.Params("token"))).First(&user).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Calendar not found",
+		})
+	}
+
+	var notes []models.Note
+	if err := database.DB.Preload("Reminders", func(db *gorm.DB) *gorm.DB {
+		return db.Order("remind_at")
+	}).Where("user_id = ? AND due_at IS NOT NULL", user.ID).Order("due_at").Find(&notes).Error; err != nil {
+		utils.LogError("Failed to get notes for calendar: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to build calendar",
+		})
+	}
+
+	entries := make([]utils.CalendarEntry, 0, len(notes))
+	for _, note := range notes {
+		entry := utils.CalendarEntry{
+			UID:         fmt.Sprintf("note-%d@notes-app", note.ID),
+			Summary:     note.Title,
+			Description: note.Content,
+			Due:         *note.DueAt,
+			Modified:    note.UpdatedAt,
+		}
+		for _, reminder := range note.Reminders {
+			entry.Alarms = append(entry.Alarms, reminder.RemindAt)
+		}
+		entries = append(entries, entry)
+	}
+
+	c.Set(fiber.HeaderContentType, "text/calendar; charset=utf-8")
+	return c.SendString(utils.RenderICalendar(user.Name+" - Notes", entries, c.Query("type") == "todo"))
+}
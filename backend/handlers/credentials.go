@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"notes-app/config"
+	"notes-app/database"
+	"notes-app/mailer"
+	"notes-app/models"
+	"notes-app/utils"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+var errEmailTaken = errors.New("email already in use")
+
+// ChangePassword sets a new password for the authenticated user, ends
+// their sessions on other devices and deletes their personal access tokens
+func ChangePassword(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	claims := c.Locals("claims").(*utils.Claims)
+
+	var req models.ChangePasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if req.CurrentPassword == "" || req.NewPassword == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Current and new password are required",
+		})
+	}
+
+	if len(req.NewPassword) < 6 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Password must be at least 6 characters",
+		})
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	if err := user.CheckPassword(req.CurrentPassword); err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Current password is incorrect",
+		})
+	}
+
+	if err := user.HashPassword(req.NewPassword); err != nil {
+		utils.LogError("Failed to hash password: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to process password",
+		})
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("password", user.Password).Error; err != nil {
+			return err
+		}
+		if err := revokeOtherSessions(tx, user.ID, claims.SessionID); err != nil {
+			return err
+		}
+		return deletePersonalAccessTokens(tx, user.ID)
+	})
+	if err != nil {
+		utils.LogError("Failed to change password: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to change password",
+		})
+	}
+
+	utils.LogInfo(fmt.Sprintf("Password changed: UserID=%d", user.ID))
+
+	return c.JSON(fiber.Map{
+		"message": "Password changed successfully",
+	})
+}
+
+// ChangeEmail starts an email change by sending a confirmation link to the
+// new address; the email is only switched once the link is opened
+func ChangeEmail(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	claims := c.Locals("claims").(*utils.Claims)
+
+	var req models.ChangeEmailRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if req.NewEmail == "" || req.Password == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "New email and password are required",
+		})
+	}
+
+	newEmail, ok := utils.NormalizeEmail(req.NewEmail)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid email address",
+		})
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	if err := user.CheckPassword(req.Password); err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Password is incorrect",
+		})
+	}
+
+	if strings.EqualFold(newEmail, user.Email) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "New email is the same as the current one",
+		})
+	}
+
+	var existingUser models.User
+	if err := database.DB.Where("LOWER(email) = ?", newEmail).First(&existingUser).Error; err == nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "User with this email already exists",
+		})
+	}
+
+	cfg := config.LoadConfig()
+	token, err := utils.GenerateEmailToken(utils.EmailClaims{
+		UserID:       user.ID,
+		Email:        newEmail,
+		Purpose:      utils.EmailTokenChange,
+		CurrentEmail: user.Email,
+		SessionID:    claims.SessionID,
+	}, cfg.EmailVerificationExpiry)
+	if err != nil {
+		utils.LogError("Failed to generate email change token: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to change email",
+		})
+	}
+
+	link := cfg.AppURL + "/confirm-email?token=" + url.QueryEscape(token)
+	err = mailer.Send(mailer.Message{
+		To:      newEmail,
+		Subject: "Confirm your new email address",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"You asked to change the email address of your Notes account to this one. "+
+			"Open this link to confirm the change:\n\n%s\n\n"+
+			"The link expires in %s. If you didn't ask for this, you can ignore this email.\n",
+			user.Name, link, cfg.EmailVerificationExpiry),
+	})
+	if err != nil {
+		utils.LogError("Failed to send email change confirmation: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to send confirmation email",
+		})
+	}
+
+	utils.LogInfo(fmt.Sprintf("Email change requested: UserID=%d", user.ID))
+
+	return c.JSON(fiber.Map{
+		"message": "Check your new email address to confirm the change",
+	})
+}
+
+// ConfirmEmailChange switches the user's email to the address the
+// confirmation link was sent to, notifies the old address, and ends the
+// user's other sessions
+func ConfirmEmailChange(c *fiber.Ctx) error {
+	var req models.VerifyEmailRequest
+	if err := c.BodyParser(&req); err != nil || req.Token == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Token is required",
+		})
+	}
+
+	claims, err := utils.ValidateEmailToken(req.Token, utils.EmailTokenChange)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid or expired confirmation link",
+		})
+	}
+
+	// The link only applies while the account still has the address it was
+	// issued for, so it can't be replayed after another change
+	var user models.User
+	if err := database.DB.Where("id = ? AND email = ?", claims.UserID, claims.CurrentEmail).First(&user).Error; err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid or expired confirmation link",
+		})
+	}
+
+	oldEmail := user.Email
+	now := time.Now()
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var existingUser models.User
+		if err := tx.Where("LOWER(email) = ?", claims.Email).First(&existingUser).Error; err == nil {
+			return errEmailTaken
+		}
+
+		err := tx.Model(&user).Updates(map[string]interface{}{
+			"email":             claims.Email,
+			"email_verified_at": now,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return revokeOtherSessions(tx, user.ID, claims.SessionID)
+	})
+	if errors.Is(err, errEmailTaken) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "User with this email already exists",
+		})
+	}
+	if err != nil {
+		utils.LogError("Failed to change email: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to change email",
+		})
+	}
+	user.Email = claims.Email
+	user.EmailVerifiedAt = &now
+
+	// Let the previous address know, in case the change wasn't wanted
+	err = mailer.Send(mailer.Message{
+		To:      oldEmail,
+		Subject: "Your email address was changed",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"The email address of your Notes account was changed from %s to %s.\n\n"+
+			"If you didn't make this change, please contact support right away.\n",
+			user.Name, oldEmail, user.Email),
+	})
+	if err != nil {
+		utils.LogError("Failed to notify previous email address: " + err.Error())
+	}
+
+	utils.LogInfo(fmt.Sprintf("Email changed: UserID=%d", user.ID))
+
+	return c.JSON(fiber.Map{
+		"message": "Email changed successfully",
+		"user":    user,
+	})
+}
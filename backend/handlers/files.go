@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"fmt"
+	"mime"
+	"net/url"
+	"notes-app/blobs"
+	"notes-app/database"
+	"notes-app/models"
+	"notes-app/storage"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ServeFile serves a locally stored upload through a signed, expiring URL
+// generated by storage.LocalStorage.SignedURL
+func ServeFile(c *fiber.Ctx) error {
+	local, ok := storage.Store.(*storage.LocalStorage)
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "File not found",
+		})
+	}
+
+	key, err := url.PathUnescape(c.Params("*"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "File not found",
+		})
+	}
+
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil || !local.VerifySignature(key, expires, c.Query("signature")) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Invalid or expired file URL",
+		})
+	}
+
+	reader, err := local.Get(key)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "File not found",
+		})
+	}
+
+	// Blob keys carry no extension, their type is recorded with the blob
+	contentType := mime.TypeByExtension(filepath.Ext(key))
+	if hash, ok := blobs.HashFromKey(key); ok {
+		var blob models.Blob
+		if database.DB.Where("hash = ?", hash).First(&blob).Error == nil {
+			contentType = blob.MimeType
+		}
+	}
+	if contentType == "" {
+		contentType = fiber.MIMEOctetStream
+	}
+	c.Set(fiber.HeaderContentType, contentType)
+	c.Set("X-Content-Type-Options", "nosniff")
+	c.Set(fiber.HeaderCacheControl, fmt.Sprintf("private, max-age=%d", expires-time.Now().Unix()))
+	return c.SendStream(reader)
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"notes-app/database"
+	"notes-app/models"
+	"notes-app/utils"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	mimeGraphML = "application/graphml+xml"
+	mimeDOT     = "text/vnd.graphviz"
+)
+
+// graphNode represents a node in the exported note graph
+type graphNode struct {
+	ID    string `json:"id"`
+	Type  string `json:"type"`
+	Label string `json:"label"`
+}
+
+// graphEdge represents an edge in the exported note graph
+type graphEdge struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Type   string `json:"type"`
+}
+
+// GetGraph exports the user's notes and the links between them as a graph.
+// JSON is returned by default; GraphML and DOT are selected through the Accept header.
+func GetGraph(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	var notes []models.Note
+	if err := database.DB.Where("user_id = ?", userID).Order("id").Find(&notes).Error; err != nil {
+		utils.LogError("Failed to get notes for graph: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to build graph",
+		})
+	}
+
+	nodes := make([]graphNode, 0, len(notes))
+	noteIDs := make([]uint, 0, len(notes))
+	for _, note := range notes {
+		nodes = append(nodes, graphNode{ID: noteNodeID(note.ID), Type: "note", Label: note.Title})
+		noteIDs = append(noteIDs, note.ID)
+	}
+
+	// Only keep links whose both ends belong to the user
+	var links []models.NoteLink
+	if len(noteIDs) > 0 {
+		if err := database.DB.Where("source_note_id IN ? AND target_note_id IN ?", noteIDs, noteIDs).Order("id").Find(&links).Error; err != nil {
+			utils.LogError("Failed to get links for graph: " + err.Error())
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to build graph",
+			})
+		}
+	}
+
+	edges := make([]graphEdge, 0, len(links))
+	for _, link := range links {
+		edges = append(edges, graphEdge{
+			Source: noteNodeID(link.SourceNoteID),
+			Target: noteNodeID(*link.TargetNoteID),
+			Type:   "link",
+		})
+	}
+
+	switch c.Accepts(fiber.MIMEApplicationJSON, mimeGraphML, mimeDOT) {
+	case mimeGraphML:
+		c.Set(fiber.HeaderContentType, mimeGraphML)
+		return c.Send(renderGraphML(nodes, edges))
+	case mimeDOT:
+		c.Set(fiber.HeaderContentType, mimeDOT)
+		return c.SendString(renderDOT(nodes, edges))
+	}
+
+	return c.JSON(fiber.Map{
+		"nodes": nodes,
+		"edges": edges,
+	})
+}
+
+// noteNodeID returns the graph node ID of a note
+func noteNodeID(id uint) string {
+	return fmt.Sprintf("note:%d", id)
+}
+
+// renderGraphML renders the graph as a GraphML document
+func renderGraphML(nodes []graphNode, edges []graphEdge) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	buf.WriteString(`<graphml xmlns="http://graphml.graphdrawing.org/xmlns">` + "\n")
+	buf.WriteString(`  <key id="type" for="all" attr.name="type" attr.type="string"/>` + "\n")
+	buf.WriteString(`  <key id="label" for="node" attr.name="label" attr.type="string"/>` + "\n")
+	buf.WriteString(`  <graph id="notes" edgedefault="directed">` + "\n")
+
+	for _, node := range nodes {
+		fmt.Fprintf(&buf, "    <node id=\"%s\">\n", xmlEscape(node.ID))
+		fmt.Fprintf(&buf, "      <data key=\"type\">%s</data>\n", xmlEscape(node.Type))
+		fmt.Fprintf(&buf, "      <data key=\"label\">%s</data>\n", xmlEscape(node.Label))
+		buf.WriteString("    </node>\n")
+	}
+	for i, edge := range edges {
+		fmt.Fprintf(&buf, "    <edge id=\"e%d\" source=\"%s\" target=\"%s\">\n", i, xmlEscape(edge.Source), xmlEscape(edge.Target))
+		fmt.Fprintf(&buf, "      <data key=\"type\">%s</data>\n", xmlEscape(edge.Type))
+		buf.WriteString("    </edge>\n")
+	}
+
+	buf.WriteString("  </graph>\n</graphml>\n")
+	return buf.Bytes()
+}
+
+// renderDOT renders the graph in Graphviz DOT format
+func renderDOT(nodes []graphNode, edges []graphEdge) string {
+	var sb strings.Builder
+	sb.WriteString("digraph notes {\n")
+	for _, node := range nodes {
+		fmt.Fprintf(&sb, "  %s [label=%s, type=%s];\n", dotQuote(node.ID), dotQuote(node.Label), dotQuote(node.Type))
+	}
+	for _, edge := range edges {
+		fmt.Fprintf(&sb, "  %s -> %s [type=%s];\n", dotQuote(edge.Source), dotQuote(edge.Target), dotQuote(edge.Type))
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// xmlEscape escapes a string for use in XML text and attributes
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// dotQuote returns s as a quoted DOT identifier
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
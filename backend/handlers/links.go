@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"notes-app/database"
+	"notes-app/models"
+	"notes-app/utils"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// GetNoteLinks retrieves the notes a note links to
+func GetNoteLinks(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	noteID := c.Params("id")
+
+	var note models.Note
+	if err := database.DB.Where("id = ? AND user_id = ?", noteID, userID).First(&note).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Note not found",
+		})
+	}
+
+	var links []models.NoteLink
+	if err := database.DB.Where("source_note_id = ?", note.ID).Order("id").Find(&links).Error; err != nil {
+		utils.LogError("Failed to get note links: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve links",
+		})
+	}
+
+	// Only load targets the caller is allowed to read
+	var targetIDs []uint
+	for _, link := range links {
+		if link.TargetNoteID != nil {
+			targetIDs = append(targetIDs, *link.TargetNoteID)
+		}
+	}
+
+	targets := make(map[uint]models.Note)
+	if len(targetIDs) > 0 {
+		var notes []models.Note
+		if err := database.DB.Where("id IN ? AND user_id = ?", targetIDs, userID).Find(&notes).Error; err != nil {
+			utils.LogError("Failed to get linked notes: " + err.Error())
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to retrieve links",
+			})
+		}
+		presentNotes(notes)
+		for _, n := range notes {
+			targets[n.ID] = n
+		}
+	}
+
+	result := make([]fiber.Map, 0, len(links))
+	for _, link := range links {
+		entry := fiber.Map{
+			"title": link.TargetTitle,
+			"note":  nil,
+		}
+		if link.TargetNoteID != nil {
+			if target, ok := targets[*link.TargetNoteID]; ok {
+				entry["title"] = target.Title
+				entry["note"] = target
+			} else if link.TargetTitle == "" {
+				// [[note:123]] link to a note the caller can't read
+				continue
+			}
+		}
+		result = append(result, entry)
+	}
+
+	return c.JSON(fiber.Map{
+		"links": result,
+	})
+}
+
+// GetNoteBacklinks retrieves the notes that link to a note
+func GetNoteBacklinks(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	noteID := c.Params("id")
+
+	var note models.Note
+	if err := database.DB.Where("id = ? AND user_id = ?", noteID, userID).First(&note).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Note not found",
+		})
+	}
+
+	sources := database.DB.Model(&models.NoteLink{}).Select("source_note_id").Where("target_note_id = ?", note.ID)
+
+	var notes []models.Note
+	if err := database.DB.Where("id IN (?) AND user_id = ?", sources, userID).Order("updated_at DESC").Find(&notes).Error; err != nil {
+		utils.LogError("Failed to get backlinks: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve backlinks",
+		})
+	}
+
+	presentNotes(notes)
+	return c.JSON(fiber.Map{
+		"backlinks": notes,
+	})
+}
+
+// syncNoteLinks replaces the outgoing links of a note with the ones in its content
+// and resolves dangling [[Title]] links elsewhere that match the note's title
+func syncNoteLinks(tx *gorm.DB, note *models.Note) error {
+	var existing []models.NoteLink
+	if err := tx.Where("source_note_id = ?", note.ID).Find(&existing).Error; err != nil {
+		return err
+	}
+
+	// Title links keep pointing at the note they resolved to, even after it is renamed
+	resolved := make(map[string]uint)
+	for _, link := range existing {
+		if link.TargetTitle != "" && link.TargetNoteID != nil {
+			resolved[strings.ToLower(link.TargetTitle)] = *link.TargetNoteID
+		}
+	}
+
+	if err := tx.Where("source_note_id = ?", note.ID).Delete(&models.NoteLink{}).Error; err != nil {
+		return err
+	}
+
+	for _, wl := range utils.ParseWikiLinks(note.Content) {
+		link := models.NoteLink{
+			SourceNoteID: note.ID,
+			TargetTitle:  wl.Title,
+		}
+
+		if wl.NoteID != 0 {
+			id := wl.NoteID
+			link.TargetNoteID = &id
+		} else if id, ok := resolved[strings.ToLower(wl.Title)]; ok && tx.Where("id = ?", id).First(&models.Note{}).Error == nil {
+			link.TargetNoteID = &id
+		} else {
+			var target models.Note
+			if err := tx.Where("user_id = ? AND LOWER(title) = LOWER(?)", note.UserID, wl.Title).Order("id").First(&target).Error; err == nil {
+				link.TargetNoteID = &target.ID
+			}
+		}
+
+		if err := tx.Create(&link).Error; err != nil {
+			return err
+		}
+	}
+
+	ownNotes := tx.Model(&models.Note{}).Select("id").Where("user_id = ?", note.UserID)
+	return tx.Model(&models.NoteLink{}).
+		Where("target_note_id IS NULL AND LOWER(target_title) = LOWER(?)", note.Title).
+		Where("source_note_id IN (?)", ownNotes).
+		Update("target_note_id", note.ID).Error
+}
+
+// removeNoteLinks drops a deleted note's outgoing links and leaves incoming [[Title]] links dangling
+func removeNoteLinks(tx *gorm.DB, note *models.Note) error {
+	if err := tx.Where("source_note_id = ?", note.ID).Delete(&models.NoteLink{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("target_note_id = ? AND target_title = ''", note.ID).Delete(&models.NoteLink{}).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.NoteLink{}).Where("target_note_id = ?", note.ID).Update("target_note_id", nil).Error
+}
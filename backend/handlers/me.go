@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"fmt"
+	"notes-app/database"
+	"notes-app/models"
+	"notes-app/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetSettings retrieves the authenticated user's settings
+func GetSettings(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"preserve_image_metadata": user.PreserveImageMetadata,
+	})
+}
+
+// UpdateSettings updates the authenticated user's settings
+func UpdateSettings(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	var req models.UpdateSettingsRequest
+	if err := c.BodyParser(&req); err != nil {
+		utils.LogError("Failed to parse update settings request: " + err.Error())
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	// Update fields if provided
+	if req.PreserveImageMetadata != nil {
+		user.PreserveImageMetadata = *req.PreserveImageMetadata
+	}
+
+	if err := database.DB.Model(&user).Update("preserve_image_metadata", user.PreserveImageMetadata).Error; err != nil {
+		utils.LogError("Failed to update settings: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update settings",
+		})
+	}
+
+	utils.LogInfo(fmt.Sprintf("Settings updated: UserID=%d", userID))
+
+	return c.JSON(fiber.Map{
+		"preserve_image_metadata": user.PreserveImageMetadata,
+	})
+}
@@ -1,14 +1,19 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"notes-app/blobs"
+	"notes-app/config"
 	"notes-app/database"
+	"notes-app/imaging"
 	"notes-app/models"
+	"notes-app/storage"
 	"notes-app/utils"
-	"path/filepath"
-	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
 // GetNotes retrieves all notes for the authenticated user
@@ -23,6 +28,7 @@ func GetNotes(c *fiber.Ctx) error {
 		})
 	}
 
+	presentNotes(notes)
 	return c.JSON(fiber.Map{
 		"notes": notes,
 	})
@@ -34,12 +40,17 @@ func GetNote(c *fiber.Ctx) error {
 	noteID := c.Params("id")
 
 	var note models.Note
-	if err := database.DB.Where("id = ? AND user_id = ?", noteID, userID).First(&note).Error; err != nil {
+	if err := database.DB.Preload("Reminders", func(db *gorm.DB) *gorm.DB {
+		return db.Order("remind_at")
+	}).Preload("Attachments", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at")
+	}).Where("id = ? AND user_id = ?", noteID, userID).First(&note).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Note not found",
 		})
 	}
 
+	presentNote(&note)
 	return c.JSON(note)
 }
 
@@ -62,14 +73,30 @@ func CreateNote(c *fiber.Ctx) error {
 		})
 	}
 
-	// Create note
 	note := models.Note{
 		UserID:  userID,
 		Title:   req.Title,
 		Content: req.Content,
+		DueAt:   req.DueAt,
+	}
+	for _, remindAt := range req.Reminders {
+		note.Reminders = append(note.Reminders, models.Reminder{UserID: userID, RemindAt: remindAt})
 	}
 
-	if err := database.DB.Create(&note).Error; err != nil {
+	return createNote(c, &note)
+}
+
+// createNote saves a new note with its links and writes the created response
+func createNote(c *fiber.Ctx, note *models.Note) error {
+	userID := note.UserID
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(note).Error; err != nil {
+			return err
+		}
+		return syncNoteLinks(tx, note)
+	})
+	if err != nil {
 		utils.LogError("Failed to create note: " + err.Error())
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to create note",
@@ -78,6 +105,7 @@ func CreateNote(c *fiber.Ctx) error {
 
 	utils.LogInfo(fmt.Sprintf("Note created: ID=%d, UserID=%d", note.ID, userID))
 
+	presentNote(note)
 	return c.Status(fiber.StatusCreated).JSON(note)
 }
 
@@ -108,8 +136,20 @@ func UpdateNote(c *fiber.Ctx) error {
 	if req.Content != "" {
 		note.Content = req.Content
 	}
+	if req.DueAt != nil {
+		note.DueAt = req.DueAt
+	}
+	if req.ClearDueAt {
+		note.DueAt = nil
+	}
 
-	if err := database.DB.Save(&note).Error; err != nil {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&note).Error; err != nil {
+			return err
+		}
+		return syncNoteLinks(tx, &note)
+	})
+	if err != nil {
 		utils.LogError("Failed to update note: " + err.Error())
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to update note",
@@ -118,6 +158,7 @@ func UpdateNote(c *fiber.Ctx) error {
 
 	utils.LogInfo(fmt.Sprintf("Note updated: ID=%d, UserID=%d", note.ID, userID))
 
+	presentNote(&note)
 	return c.JSON(note)
 }
 
@@ -133,13 +174,45 @@ func DeleteNote(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := database.DB.Delete(&note).Error; err != nil {
+	var attachments []models.Attachment
+	var uploads []models.UploadSession
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&note).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("note_id = ?", note.ID).Delete(&models.Reminder{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("note_id = ?", note.ID).Find(&attachments).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("note_id = ?", note.ID).Delete(&models.Attachment{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("note_id = ?", note.ID).Find(&uploads).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("note_id = ?", note.ID).Delete(&models.UploadSession{}).Error; err != nil {
+			return err
+		}
+		return removeNoteLinks(tx, &note)
+	})
+	if err != nil {
 		utils.LogError("Failed to delete note: " + err.Error())
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to delete note",
 		})
 	}
 
+	// Drop the note's references to its files; unreferenced blobs are deleted
+	releaseFiles(note.ImageKey, note.ImageMediumKey, note.ImageThumbnailKey)
+	for _, attachment := range attachments {
+		releaseFiles(attachment.StorageKey)
+	}
+	for i := range uploads {
+		deleteUploadChunks(&uploads[i])
+	}
+
 	utils.LogInfo(fmt.Sprintf("Note deleted: ID=%d, UserID=%d", note.ID, userID))
 
 	return c.JSON(fiber.Map{
@@ -168,37 +241,169 @@ func UploadImage(c *fiber.Ctx) error {
 		})
 	}
 
-	// Validate file type
-	ext := filepath.Ext(file.Filename)
-	allowedExts := map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".gif": true}
-	if !allowedExts[ext] {
+	cfg := config.LoadConfig()
+	if file.Size > cfg.MaxUploadBytes {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+			"error": fmt.Sprintf("Image is too large, maximum size is %d bytes", cfg.MaxUploadBytes),
+		})
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		utils.LogError("Failed to open uploaded image: " + err.Error())
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid file type. Only jpg, jpeg, png, and gif are allowed",
+			"error": "Failed to read image",
 		})
 	}
+	defer src.Close()
 
-	// Generate unique filename
-	filename := fmt.Sprintf("%d_%d%s", time.Now().Unix(), note.ID, ext)
-	filepath := fmt.Sprintf("./uploads/%s", filename)
+	data, err := io.ReadAll(io.LimitReader(src, cfg.MaxUploadBytes+1))
+	if err != nil {
+		utils.LogError("Failed to read uploaded image: " + err.Error())
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Failed to read image",
+		})
+	}
 
-	// Save file
-	if err := c.SaveFile(file, filepath); err != nil {
-		utils.LogError("Failed to save image: " + err.Error())
+	// Validate file type by content, not by name
+	info, err := imaging.Validate(data, file.Filename, imaging.Limits{
+		MaxBytes:  cfg.MaxUploadBytes,
+		MaxWidth:  cfg.MaxImageWidth,
+		MaxHeight: cfg.MaxImageHeight,
+	})
+	if errors.Is(err, imaging.ErrTooLarge) {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+			"error": "Invalid image: " + err.Error(),
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid image: " + err.Error(),
+		})
+	}
+
+	// Strip EXIF/GPS metadata unless the user opted to keep it
+	var user models.User
+	if err := database.DB.Select("id", "preserve_image_metadata").First(&user, userID).Error; err != nil {
+		utils.LogError("Failed to load user settings: " + err.Error())
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to save image",
 		})
 	}
+	if !user.PreserveImageMetadata {
+		if data, err = imaging.StripMetadata(data, info); err != nil {
+			utils.LogError("Failed to strip image metadata: " + err.Error())
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid image: failed to decode image data",
+			})
+		}
+	}
+
+	variants, err := imaging.GenerateVariants(data, info)
+	if err != nil {
+		utils.LogError("Failed to generate image variants: " + err.Error())
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid image: failed to decode image data",
+		})
+	}
 
-	// Update note with image URL
-	note.ImageURL = "/uploads/" + filename
-	if err := database.DB.Save(&note).Error; err != nil {
-		utils.LogError("Failed to update note with image URL: " + err.Error())
+	// Enforce the storage quota; the replaced image's bytes are freed
+	total := int64(len(data))
+	for _, v := range variants {
+		total += int64(len(v.Data))
+	}
+	if ok, err := checkQuota(c, userID, total, note.ImageBytes); !ok {
+		return err
+	}
+
+	// Store the original and its variants as content-addressed blobs
+	keys := make(map[string]string, len(variants)+1)
+	files := append([]imaging.Variant{{Name: "original", Data: data, MIME: info.MIME}}, variants...)
+	for _, f := range files {
+		key, err := blobs.AcquireBytes(f.Data, f.MIME)
+		if err != nil {
+			utils.LogError("Failed to save image: " + err.Error())
+			releaseFiles(mapValues(keys)...)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to save image",
+			})
+		}
+		keys[f.Name] = key
+	}
+
+	// Update note with image keys. The note is reloaded under the user's
+	// storage lock, so the bytes freed and the keys replaced are those of
+	// the image it has now.
+	var oldKeys []string
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockStorage(tx, userID); err != nil {
+			return err
+		}
+		if err := tx.Where("id = ? AND user_id = ?", note.ID, userID).First(&note).Error; err != nil {
+			return err
+		}
+		if err := reserveQuota(tx, userID, total, note.ImageBytes); err != nil {
+			return err
+		}
+
+		oldKeys = []string{note.ImageKey, note.ImageMediumKey, note.ImageThumbnailKey}
+		note.ImageKey = keys["original"]
+		note.ImageMediumKey = keys["medium"]
+		note.ImageThumbnailKey = keys["thumbnail"]
+		note.ImageBytes = total
+		return tx.Save(&note).Error
+	})
+	if errors.Is(err, errQuotaExceeded) {
+		releaseFiles(mapValues(keys)...)
+		return quotaExceeded(c, userID, total)
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		releaseFiles(mapValues(keys)...)
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Note not found",
+		})
+	}
+	if err != nil {
+		utils.LogError("Failed to update note with image: " + err.Error())
+		releaseFiles(mapValues(keys)...)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to update note",
 		})
 	}
 
+	// The replaced image is no longer referenced by this note
+	releaseFiles(oldKeys...)
+
 	utils.LogInfo(fmt.Sprintf("Image uploaded for note: ID=%d, UserID=%d", note.ID, userID))
 
+	presentNote(&note)
 	return c.JSON(note)
 }
+
+// presentNote fills in the response-only fields of a note
+func presentNote(note *models.Note) {
+	if note.ImageKey == "" {
+		return
+	}
+
+	// Images uploaded before variants existed fall back to the original
+	original := storage.URL(note.ImageKey)
+	note.Images = &models.NoteImages{
+		Original:  original,
+		Medium:    original,
+		Thumbnail: original,
+	}
+	if note.ImageMediumKey != "" {
+		note.Images.Medium = storage.URL(note.ImageMediumKey)
+	}
+	if note.ImageThumbnailKey != "" {
+		note.Images.Thumbnail = storage.URL(note.ImageThumbnailKey)
+	}
+}
+
+// presentNotes fills in the response-only fields of a list of notes
+func presentNotes(notes []models.Note) {
+	for i := range notes {
+		presentNote(&notes[i])
+	}
+}
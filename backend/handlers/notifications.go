@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"notes-app/database"
+	"notes-app/models"
+	"notes-app/utils"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetNotifications retrieves the latest notifications of the authenticated user
+func GetNotifications(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	query := database.DB.Where("user_id = ?", userID)
+	if c.Query("unread") == "true" {
+		query = query.Where("read_at IS NULL")
+	}
+
+	var notifications []models.Notification
+	if err := query.Order("created_at DESC").Limit(100).Find(&notifications).Error; err != nil {
+		utils.LogError("Failed to get notifications: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve notifications",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"notifications": notifications,
+	})
+}
+
+// MarkNotificationRead marks a notification as read
+func MarkNotificationRead(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	var notification models.Notification
+	if err := database.DB.Where("id = ? AND user_id = ?", c.Params("id"), userID).First(&notification).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Notification not found",
+		})
+	}
+
+	if notification.ReadAt == nil {
+		now := time.Now()
+		notification.ReadAt = &now
+		if err := database.DB.Save(&notification).Error; err != nil {
+			utils.LogError("Failed to update notification: " + err.Error())
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to update notification",
+			})
+		}
+	}
+
+	return c.JSON(notification)
+}
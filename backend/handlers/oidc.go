@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"notes-app/config"
+	"notes-app/database"
+	"notes-app/models"
+	"notes-app/oidc"
+	"notes-app/utils"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+var (
+	errOIDCEmailUnverified   = errors.New("identity provider did not verify the email address")
+	errOIDCAccountUnverified = errors.New("existing account has an unverified email address")
+	errOIDCUserGone          = errors.New("linked user no longer exists")
+)
+
+// GetOIDCProviders lists the identity providers users can log in with
+func GetOIDCProviders(c *fiber.Ctx) error {
+	providers := make([]fiber.Map, len(oidc.Providers))
+	for i, p := range oidc.Providers {
+		providers[i] = fiber.Map{"id": p.ID, "name": p.Name}
+	}
+
+	return c.JSON(fiber.Map{
+		"providers": providers,
+	})
+}
+
+// BeginOIDCLogin starts a single sign-on login and returns the provider URL
+// to send the user to. The provider redirects back to the frontend, which
+// passes the state and code on to FinishOIDCLogin.
+//
+// The state travels through the provider's URLs, so it alone doesn't prove
+// the callback comes from the browser that started the login. The browser
+// secret does: the frontend keeps it in session storage, and without it an
+// attacker can't make a victim's browser finish the attacker's login.
+func BeginOIDCLogin(c *fiber.Ctx) error {
+	provider, err := oidc.Get(c.Params("provider"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Unknown identity provider",
+		})
+	}
+
+	state, err1 := utils.GenerateSecureToken(32)
+	nonce, err2 := utils.GenerateSecureToken(32)
+	codeVerifier, err3 := oidc.GenerateCodeVerifier()
+	browserSecret, err4 := utils.GenerateSecureToken(32)
+	if err := errors.Join(err1, err2, err3, err4); err != nil {
+		utils.LogError("Failed to generate single sign-on state: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to start single sign-on",
+		})
+	}
+
+	cfg := config.LoadConfig()
+	authURL, err := provider.AuthCodeURL(c.UserContext(), cfg.OIDCRedirectURL, state, nonce, codeVerifier)
+	if err != nil {
+		utils.LogError("Failed to reach identity provider: " + err.Error())
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"error": "Identity provider is unavailable",
+		})
+	}
+
+	err = database.DB.Create(&models.OIDCLogin{
+		Provider:          provider.ID,
+		StateHash:         utils.HashToken(state),
+		BrowserSecretHash: utils.HashToken(browserSecret),
+		Nonce:             nonce,
+		CodeVerifier:      codeVerifier,
+		ExpiresAt:         time.Now().Add(cfg.OIDCLoginTTL),
+	}).Error
+	if err != nil {
+		utils.LogError("Failed to save single sign-on login: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to start single sign-on",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"authorization_url": authURL,
+		"state":             state,
+		"browser_secret":    browserSecret,
+		"expires_in":        int(cfg.OIDCLoginTTL.Seconds()),
+	})
+}
+
+// FinishOIDCLogin redeems the authorization code the provider redirected
+// back with, logs in the user the verified ID token belongs to and issues
+// the app's own tokens
+func FinishOIDCLogin(c *fiber.Ctx) error {
+	var req models.OIDCCallbackRequest
+	if err := c.BodyParser(&req); err != nil || req.State == "" || req.Code == "" || req.BrowserSecret == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "State, code and browser secret are required",
+		})
+	}
+
+	// The login is deleted on first use, so a state can't be replayed
+	var login models.OIDCLogin
+	err := database.DB.Where("state_hash = ? AND browser_secret_hash = ? AND expires_at > ?",
+		utils.HashToken(req.State), utils.HashToken(req.BrowserSecret), time.Now()).First(&login).Error
+	if err == nil {
+		result := database.DB.Delete(&login)
+		if result.Error == nil && result.RowsAffected == 0 {
+			err = gorm.ErrRecordNotFound
+		} else {
+			err = result.Error
+		}
+	}
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.LogError("Failed to load single sign-on login: " + err.Error())
+		}
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Single sign-on login has expired, please try again",
+		})
+	}
+
+	provider, err := oidc.Get(login.Provider)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Unknown identity provider",
+		})
+	}
+
+	idToken, err := provider.Exchange(c.UserContext(), config.LoadConfig().OIDCRedirectURL, req.Code, login.CodeVerifier, login.Nonce)
+	if err != nil {
+		utils.LogWarning(fmt.Sprintf("Single sign-on rejected: Provider=%s: %s", provider.ID, err.Error()))
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Single sign-on failed",
+		})
+	}
+
+	var user models.User
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		return findOrCreateOIDCUser(tx, provider.ID, idToken, &user)
+	})
+	switch {
+	case errors.Is(err, errOIDCEmailUnverified):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Your identity provider account has no verified email address",
+		})
+	case errors.Is(err, errOIDCAccountUnverified):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "An account with this email already exists; verify its email address or log in with your password first",
+		})
+	case errors.Is(err, errOIDCUserGone):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "The linked account no longer exists",
+		})
+	case err != nil:
+		utils.LogError("Failed to log in with single sign-on: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to log in",
+		})
+	}
+
+	return finishLogin(c, &user, fmt.Sprintf("User logged in with %s: ", provider.ID))
+}
+
+// findOrCreateOIDCUser loads the user linked to the provider account. An
+// unlinked account is linked to the user with the same verified email, or
+// a new user is created for it.
+func findOrCreateOIDCUser(tx *gorm.DB, providerID string, idToken *oidc.IDToken, user *models.User) error {
+	now := time.Now()
+
+	var identity models.OIDCIdentity
+	err := tx.Where("provider = ? AND subject = ?", providerID, idToken.Subject).First(&identity).Error
+	if err == nil {
+		if err := tx.First(user, identity.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errOIDCUserGone
+			}
+			return err
+		}
+		return tx.Model(&identity).Updates(map[string]interface{}{
+			"email":         idToken.Email,
+			"last_login_at": now,
+		}).Error
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	email, err := oidcLinkEmail(idToken)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Where("LOWER(email) = ?", email).First(user).Error
+	switch {
+	case err == nil:
+		// Whoever registered an unverified address may not own it, and
+		// linking would hand them the provider account's logins
+		if user.EmailVerifiedAt == nil {
+			return errOIDCAccountUnverified
+		}
+		utils.LogInfo(fmt.Sprintf("Linking %s identity to existing user: UserID=%d", providerID, user.ID))
+
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		name := idToken.Name
+		if name == "" {
+			name = strings.SplitN(email, "@", 2)[0]
+		}
+		*user = models.User{
+			Name:            name,
+			Email:           email,
+			EmailVerifiedAt: &now,
+		}
+
+		// The account has no usable password until the user resets it
+		password, err := utils.GenerateSecureToken(32)
+		if err != nil {
+			return err
+		}
+		if err := user.HashPassword(password); err != nil {
+			return err
+		}
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		utils.LogInfo(fmt.Sprintf("User provisioned by %s: %s", providerID, user.Email))
+
+	default:
+		return err
+	}
+
+	return tx.Create(&models.OIDCIdentity{
+		UserID:      user.ID,
+		Provider:    providerID,
+		Subject:     idToken.Subject,
+		Email:       email,
+		LastLoginAt: &now,
+	}).Error
+}
+
+// oidcLinkEmail returns the address an unlinked provider account is matched
+// to an existing user by, or provisioned with. Only an address the provider
+// verified is used, so nobody can claim an account by naming its email.
+func oidcLinkEmail(idToken *oidc.IDToken) (string, error) {
+	email, ok := utils.NormalizeEmail(idToken.Email)
+	if !ok || !idToken.EmailVerified {
+		return "", errOIDCEmailUnverified
+	}
+	return email, nil
+}
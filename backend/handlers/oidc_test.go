@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"errors"
+	"notes-app/oidc"
+	"testing"
+)
+
+func TestOIDCLinkEmail(t *testing.T) {
+	tests := []struct {
+		name    string
+		idToken oidc.IDToken
+		want    string
+		wantErr error
+	}{
+		{"verified", oidc.IDToken{Email: "Jane@Example.com", EmailVerified: true}, "jane@example.com", nil},
+		{"unverified", oidc.IDToken{Email: "jane@example.com"}, "", errOIDCEmailUnverified},
+		{"no email", oidc.IDToken{EmailVerified: true}, "", errOIDCEmailUnverified},
+		{"invalid email", oidc.IDToken{Email: "Jane <jane@example.com>", EmailVerified: true}, "", errOIDCEmailUnverified},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := oidcLinkEmail(&tt.idToken)
+			if got != tt.want || !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got %q, %v; want %q, %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,373 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"notes-app/config"
+	"notes-app/database"
+	"notes-app/models"
+	"notes-app/utils"
+	"notes-app/webauthn"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// Passkey login is usernameless: registration requires a discoverable
+// credential, so the authenticator itself tells us which account signs in.
+
+// coseAlgorithms are the credential key algorithms offered at registration, in order of preference
+var coseAlgorithms = []int{webauthn.AlgES256, webauthn.AlgEdDSA, webauthn.AlgRS256}
+
+// BeginPasskeyRegistration returns the options for navigator.credentials.create()
+func BeginPasskeyRegistration(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	var passkeys []models.Passkey
+	if err := database.DB.Where("user_id = ?", user.ID).Find(&passkeys).Error; err != nil {
+		utils.LogError("Failed to get passkeys: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to start passkey registration",
+		})
+	}
+
+	challenge, err := createWebAuthnChallenge(&user.ID, models.CeremonyRegistration)
+	if err != nil {
+		utils.LogError("Failed to create WebAuthn challenge: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to start passkey registration",
+		})
+	}
+
+	cfg := config.LoadConfig()
+
+	params := make([]fiber.Map, len(coseAlgorithms))
+	for i, alg := range coseAlgorithms {
+		params[i] = fiber.Map{"type": "public-key", "alg": alg}
+	}
+
+	// Don't let the same authenticator register twice
+	exclude := make([]fiber.Map, len(passkeys))
+	for i, passkey := range passkeys {
+		exclude[i] = fiber.Map{"type": "public-key", "id": passkey.CredentialID}
+	}
+
+	return c.JSON(fiber.Map{
+		"publicKey": fiber.Map{
+			"challenge": challenge,
+			"rp": fiber.Map{
+				"id":   cfg.WebAuthnRPID,
+				"name": cfg.WebAuthnRPName,
+			},
+			"user": fiber.Map{
+				"id":          base64.RawURLEncoding.EncodeToString(userHandle(user.ID)),
+				"name":        user.Email,
+				"displayName": user.Name,
+			},
+			"pubKeyCredParams":   params,
+			"excludeCredentials": exclude,
+			"authenticatorSelection": fiber.Map{
+				"residentKey":        "required",
+				"requireResidentKey": true,
+				"userVerification":   "preferred",
+			},
+			"attestation": "none",
+			"timeout":     cfg.WebAuthnTimeout.Milliseconds(),
+		},
+	})
+}
+
+// FinishPasskeyRegistration verifies the authenticator's response and stores the new passkey
+func FinishPasskeyRegistration(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	var req models.FinishPasskeyRegistrationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	clientDataJSON, err1 := base64.RawURLEncoding.DecodeString(req.Credential.Response.ClientDataJSON)
+	attestationObject, err2 := base64.RawURLEncoding.DecodeString(req.Credential.Response.AttestationObject)
+	if err1 != nil || err2 != nil || len(clientDataJSON) == 0 || len(attestationObject) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid credential",
+		})
+	}
+
+	challenge, err := consumeWebAuthnChallenge(clientDataJSON, &userID, models.CeremonyRegistration)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Passkey registration has expired, please try again",
+		})
+	}
+
+	credential, err := webAuthnConfig().VerifyRegistration(challenge, clientDataJSON, attestationObject)
+	if err != nil {
+		utils.LogWarning(fmt.Sprintf("Passkey registration rejected: UserID=%d: %s", userID, err.Error()))
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Passkey could not be verified",
+		})
+	}
+
+	credentialID := base64.RawURLEncoding.EncodeToString(credential.ID)
+	var existing models.Passkey
+	if err := database.DB.Where("credential_id = ?", credentialID).First(&existing).Error; err == nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "This passkey is already registered",
+		})
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = "Passkey"
+	}
+
+	passkey := models.Passkey{
+		UserID:         userID,
+		Name:           name,
+		CredentialID:   credentialID,
+		PublicKey:      credential.PublicKey,
+		SignCount:      int64(credential.SignCount),
+		BackupEligible: credential.BackupEligible,
+	}
+	if err := database.DB.Create(&passkey).Error; err != nil {
+		utils.LogError("Failed to save passkey: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to save passkey",
+		})
+	}
+
+	utils.LogInfo(fmt.Sprintf("Passkey registered: ID=%d, UserID=%d", passkey.ID, userID))
+
+	return c.Status(fiber.StatusCreated).JSON(passkey)
+}
+
+// GetPasskeys lists the user's passkeys
+func GetPasskeys(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	var passkeys []models.Passkey
+	if err := database.DB.Where("user_id = ?", userID).Order("created_at").Find(&passkeys).Error; err != nil {
+		utils.LogError("Failed to get passkeys: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve passkeys",
+		})
+	}
+
+	return c.JSON(passkeys)
+}
+
+// DeletePasskey removes one of the user's passkeys
+func DeletePasskey(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	result := database.DB.Where("id = ? AND user_id = ?", c.Params("id"), userID).Delete(&models.Passkey{})
+	if result.Error != nil {
+		utils.LogError("Failed to delete passkey: " + result.Error.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete passkey",
+		})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Passkey not found",
+		})
+	}
+
+	utils.LogInfo(fmt.Sprintf("Passkey deleted: ID=%s, UserID=%d", c.Params("id"), userID))
+
+	return c.JSON(fiber.Map{
+		"message": "Passkey deleted successfully",
+	})
+}
+
+// BeginPasskeyLogin returns the options for navigator.credentials.get()
+func BeginPasskeyLogin(c *fiber.Ctx) error {
+	challenge, err := createWebAuthnChallenge(nil, models.CeremonyAuthentication)
+	if err != nil {
+		utils.LogError("Failed to create WebAuthn challenge: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to start passkey login",
+		})
+	}
+
+	cfg := config.LoadConfig()
+	return c.JSON(fiber.Map{
+		"publicKey": fiber.Map{
+			"challenge":        challenge,
+			"rpId":             cfg.WebAuthnRPID,
+			"allowCredentials": []fiber.Map{},
+			"userVerification": "preferred",
+			"timeout":          cfg.WebAuthnTimeout.Milliseconds(),
+		},
+	})
+}
+
+// FinishPasskeyLogin verifies a passkey assertion and logs the user in,
+// issuing the same tokens as a password login. A passkey the authenticator
+// unlocked with a PIN or biometric counts as two factors; one that only
+// proved presence is a single factor, so accounts with 2FA still need a code.
+func FinishPasskeyLogin(c *fiber.Ctx) error {
+	var req models.FinishPasskeyLoginRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	response := req.Credential.Response
+	clientDataJSON, err1 := base64.RawURLEncoding.DecodeString(response.ClientDataJSON)
+	authenticatorData, err2 := base64.RawURLEncoding.DecodeString(response.AuthenticatorData)
+	signature, err3 := base64.RawURLEncoding.DecodeString(response.Signature)
+	if err1 != nil || err2 != nil || err3 != nil || req.Credential.RawID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid credential",
+		})
+	}
+
+	challenge, err := consumeWebAuthnChallenge(clientDataJSON, nil, models.CeremonyAuthentication)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Passkey login has expired, please try again",
+		})
+	}
+
+	var passkey models.Passkey
+	if err := database.DB.Where("credential_id = ?", req.Credential.RawID).First(&passkey).Error; err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Unknown passkey",
+		})
+	}
+
+	// A discoverable credential names its account; it must be the passkey's owner
+	if response.UserHandle != "" && response.UserHandle != base64.RawURLEncoding.EncodeToString(userHandle(passkey.UserID)) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Unknown passkey",
+		})
+	}
+
+	assertion, err := webAuthnConfig().VerifyAssertion(challenge, clientDataJSON, authenticatorData, signature,
+		passkey.PublicKey, uint32(passkey.SignCount))
+	if err != nil {
+		utils.LogWarning(fmt.Sprintf("Passkey login rejected: PasskeyID=%d, UserID=%d: %s", passkey.ID, passkey.UserID, err.Error()))
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Passkey could not be verified",
+		})
+	}
+
+	// The counter check guards against concurrent logins with a cloned authenticator
+	result := database.DB.Model(&models.Passkey{}).
+		Where("id = ? AND sign_count = ?", passkey.ID, passkey.SignCount).
+		Updates(map[string]interface{}{
+			"sign_count":   int64(assertion.SignCount),
+			"last_used_at": time.Now(),
+		})
+	if result.Error != nil || result.RowsAffected == 0 {
+		if result.Error != nil {
+			utils.LogError("Failed to update passkey: " + result.Error.Error())
+		}
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Passkey could not be verified",
+		})
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, passkey.UserID).Error; err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Unknown passkey",
+		})
+	}
+
+	if !assertion.UserVerified {
+		return finishLogin(c, &user, "User logged in with passkey: ")
+	}
+
+	token, refreshToken, err := startSession(c, &user)
+	if err != nil {
+		utils.LogError("Failed to generate token: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to generate token",
+		})
+	}
+
+	utils.LogInfo("User logged in with verified passkey: " + user.Email)
+
+	return c.JSON(tokenResponse("Login successful", token, refreshToken, &user))
+}
+
+// webAuthnConfig returns the relying party configuration
+func webAuthnConfig() *webauthn.Config {
+	cfg := config.LoadConfig()
+	return &webauthn.Config{
+		RPID:    cfg.WebAuthnRPID,
+		RPName:  cfg.WebAuthnRPName,
+		Origins: cfg.WebAuthnOrigins,
+	}
+}
+
+// createWebAuthnChallenge stores a new random challenge for a ceremony and
+// returns it base64url encoded
+func createWebAuthnChallenge(userID *uint, ceremony string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	challenge := base64.RawURLEncoding.EncodeToString(b)
+
+	err := database.DB.Create(&models.WebAuthnChallenge{
+		UserID:        userID,
+		Ceremony:      ceremony,
+		ChallengeHash: utils.HashToken(challenge),
+		ExpiresAt:     time.Now().Add(config.LoadConfig().WebAuthnTimeout),
+	}).Error
+	if err != nil {
+		return "", err
+	}
+	return challenge, nil
+}
+
+// consumeWebAuthnChallenge deletes the pending challenge a client response
+// was made for and returns it; it fails if there is no such live challenge
+func consumeWebAuthnChallenge(clientDataJSON []byte, userID *uint, ceremony string) ([]byte, error) {
+	challenge, err := webauthn.ClientDataChallenge(clientDataJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	query := database.DB.Where("challenge_hash = ? AND ceremony = ? AND expires_at > ?",
+		utils.HashToken(base64.RawURLEncoding.EncodeToString(challenge)), ceremony, time.Now())
+	if userID != nil {
+		query = query.Where("user_id = ?", *userID)
+	} else {
+		query = query.Where("user_id IS NULL")
+	}
+
+	result := query.Delete(&models.WebAuthnChallenge{})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return challenge, nil
+}
+
+// userHandle is the WebAuthn user ID of a user: opaque bytes, no personal data
+func userHandle(userID uint) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(userID))
+	return b
+}
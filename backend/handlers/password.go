@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"notes-app/config"
+	"notes-app/database"
+	"notes-app/mailer"
+	"notes-app/models"
+	"notes-app/utils"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errInvalidResetToken = errors.New("invalid password reset token")
+	errResetThrottled    = errors.New("password reset link sent recently")
+)
+
+// ForgotPassword emails a password reset link. The response is the same
+// whether or not an account exists, so it can't be used to probe emails.
+func ForgotPassword(c *fiber.Ctx) error {
+	var req models.ForgotPasswordRequest
+	if err := c.BodyParser(&req); err != nil || strings.TrimSpace(req.Email) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Email is required",
+		})
+	}
+
+	// Look up the account and send the email in the background, so the
+	// response time doesn't reveal whether the account exists either
+	go sendPasswordReset(strings.ToLower(strings.TrimSpace(req.Email)))
+
+	return c.JSON(fiber.Map{
+		"message": "If an account exists for this email, a password reset link has been sent",
+	})
+}
+
+// ResetPassword sets a new password using an emailed reset token and ends
+// all of the user's sessions
+func ResetPassword(c *fiber.Ctx) error {
+	var req models.ResetPasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if req.Token == "" || req.Password == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Token and password are required",
+		})
+	}
+
+	if len(req.Password) < 6 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Password must be at least 6 characters",
+		})
+	}
+
+	var user models.User
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// The row lock keeps the token from being used twice concurrently
+		var token models.PasswordResetToken
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", utils.HashToken(req.Token)).First(&token).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errInvalidResetToken
+		}
+		if err != nil {
+			return err
+		}
+		if token.UsedAt != nil || time.Now().After(token.ExpiresAt) {
+			return errInvalidResetToken
+		}
+
+		if err := tx.First(&user, token.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errInvalidResetToken
+			}
+			return err
+		}
+
+		if err := user.HashPassword(req.Password); err != nil {
+			return err
+		}
+
+		// Invalidate this and any other outstanding reset links
+		err = tx.Model(&models.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", user.ID).
+			Update("used_at", time.Now()).Error
+		if err != nil {
+			return err
+		}
+
+		// Whoever knew the old password must not stay logged in
+		err = tx.Model(&user).Updates(map[string]interface{}{
+			"password":         user.Password,
+			"token_generation": gorm.Expr("token_generation + 1"),
+		}).Error
+		if err != nil {
+			return err
+		}
+		return revokeUserTokens(tx, user.ID)
+	})
+
+	if errors.Is(err, errInvalidResetToken) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid or expired reset token",
+		})
+	}
+	if err != nil {
+		utils.LogError("Failed to reset password: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to reset password",
+		})
+	}
+
+	utils.LogInfo(fmt.Sprintf("Password reset: UserID=%d", user.ID))
+
+	return c.JSON(fiber.Map{
+		"message": "Password has been reset, please log in with your new password",
+	})
+}
+
+// sendPasswordReset creates a reset token for the account with the given
+// email, if there is one, and emails the reset link. Nothing is sent while
+// a link from the last PasswordResetCooldown is still valid.
+func sendPasswordReset(email string) {
+	token, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		utils.LogError("Failed to generate password reset token: " + err.Error())
+		return
+	}
+
+	cfg := config.LoadConfig()
+	var user models.User
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// The row lock keeps concurrent requests from each sending a link
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("LOWER(email) = ?", email).First(&user).Error; err != nil {
+			return err
+		}
+
+		// Don't let the endpoint be used to flood someone's inbox
+		var recent int64
+		err := tx.Model(&models.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL AND expires_at > ? AND created_at > ?",
+				user.ID, time.Now(), time.Now().Add(-cfg.PasswordResetCooldown)).
+			Count(&recent).Error
+		if err != nil {
+			return err
+		}
+		if recent > 0 {
+			return errResetThrottled
+		}
+
+		return tx.Create(&models.PasswordResetToken{
+			UserID:    user.ID,
+			TokenHash: utils.HashToken(token),
+			ExpiresAt: time.Now().Add(cfg.PasswordResetExpiry),
+		}).Error
+	})
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return
+	case errors.Is(err, errResetThrottled):
+		utils.LogInfo(fmt.Sprintf("Password reset not sent, a recent link is still valid: UserID=%d", user.ID))
+		return
+	case err != nil:
+		utils.LogError("Failed to create password reset token: " + err.Error())
+		return
+	}
+
+	link := cfg.AppURL + "/reset-password?token=" + url.QueryEscape(token)
+	err = mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Someone asked to reset the password of your Notes account. "+
+			"If it was you, open this link to choose a new password:\n\n%s\n\n"+
+			"The link expires in %s and can be used once. "+
+			"If you didn't ask for this, you can ignore this email.\n",
+			user.Name, link, cfg.PasswordResetExpiry),
+	})
+	if err != nil {
+		utils.LogError("Failed to send password reset email: " + err.Error())
+		return
+	}
+
+	utils.LogInfo(fmt.Sprintf("Password reset requested: UserID=%d", user.ID))
+}
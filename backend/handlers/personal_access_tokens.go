@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"fmt"
+	"notes-app/database"
+	"notes-app/models"
+	"notes-app/utils"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// GetPersonalAccessTokens lists the user's personal access tokens
+func GetPersonalAccessTokens(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	var tokens []models.PersonalAccessToken
+	if err := database.DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&tokens).Error; err != nil {
+		utils.LogError("Failed to get personal access tokens: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve tokens",
+		})
+	}
+
+	return c.JSON(tokens)
+}
+
+// CreatePersonalAccessToken creates a personal access token with the given
+// scopes. The token itself is only returned in this response.
+func CreatePersonalAccessToken(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	var req models.CreatePersonalAccessTokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 100 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Name is required and must be at most 100 characters",
+		})
+	}
+
+	if len(req.Scopes) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "At least one scope is required: " + strings.Join(models.Scopes, ", "),
+		})
+	}
+	for _, scope := range req.Scopes {
+		if !models.IsValidScope(scope) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Unknown scope: " + scope,
+			})
+		}
+	}
+
+	// Store the scopes in their canonical order, without duplicates
+	requested := models.PersonalAccessToken{Scopes: req.Scopes}
+	var scopes []string
+	for _, scope := range models.Scopes {
+		if requested.HasScope(scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Expiry must be in the future",
+		})
+	}
+
+	secret, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		utils.LogError("Failed to generate personal access token: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create token",
+		})
+	}
+	tokenString := models.PersonalAccessTokenPrefix + secret
+
+	token := models.PersonalAccessToken{
+		UserID:    userID,
+		Name:      req.Name,
+		TokenHash: utils.HashToken(tokenString),
+		Prefix:    tokenString[:len(models.PersonalAccessTokenPrefix)+6],
+		Scopes:    scopes,
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err := database.DB.Create(&token).Error; err != nil {
+		utils.LogError("Failed to create personal access token: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create token",
+		})
+	}
+
+	utils.LogInfo(fmt.Sprintf("Personal access token created: ID=%d, UserID=%d, Scopes=%s",
+		token.ID, userID, strings.Join(scopes, " ")))
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message":               "Token created; copy it now, it won't be shown again",
+		"token":                 tokenString,
+		"personal_access_token": token,
+	})
+}
+
+// DeletePersonalAccessToken revokes one of the user's personal access tokens
+func DeletePersonalAccessToken(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	result := database.DB.Where("id = ? AND user_id = ?", c.Params("id"), userID).Delete(&models.PersonalAccessToken{})
+	if result.Error != nil {
+		utils.LogError("Failed to delete personal access token: " + result.Error.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete token",
+		})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Token not found",
+		})
+	}
+
+	utils.LogInfo(fmt.Sprintf("Personal access token deleted: ID=%s, UserID=%d", c.Params("id"), userID))
+
+	return c.JSON(fiber.Map{
+		"message": "Token deleted successfully",
+	})
+}
+
+// deletePersonalAccessTokens revokes all of a user's personal access tokens,
+// for when whoever created them may not have been the account owner
+func deletePersonalAccessTokens(db *gorm.DB, userID uint) error {
+	return db.Where("user_id = ?", userID).Delete(&models.PersonalAccessToken{}).Error
+}
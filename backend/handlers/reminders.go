@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"fmt"
+	"notes-app/database"
+	"notes-app/models"
+	"notes-app/utils"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetDueNotes retrieves notes with a due date before the given time (default: one week from now)
+func GetDueNotes(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	before := time.Now().Add(7 * 24 * time.Hour)
+	if value := c.Query("before"); value != "" {
+		parsed, err := parseTimeQuery(value)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid before parameter, expected RFC 3339 time or YYYY-MM-DD date",
+			})
+		}
+		before = parsed
+	}
+
+	var notes []models.Note
+	if err := database.DB.Where("user_id = ? AND due_at IS NOT NULL AND due_at <= ?", userID, before).
+		Order("due_at").Find(&notes).Error; err != nil {
+		utils.LogError("Failed to get due notes: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve due notes",
+		})
+	}
+
+	presentNotes(notes)
+	return c.JSON(fiber.Map{
+		"notes": notes,
+	})
+}
+
+// CreateReminder adds a reminder to a note
+func CreateReminder(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	noteID := c.Params("id")
+
+	var note models.Note
+	if err := database.DB.Where("id = ? AND user_id = ?", noteID, userID).First(&note).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Note not found",
+		})
+	}
+
+	var req models.CreateReminderRequest
+	if err := c.BodyParser(&req); err != nil {
+		utils.LogError("Failed to parse create reminder request: " + err.Error())
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if req.RemindAt.IsZero() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "remind_at is required",
+		})
+	}
+
+	reminder := models.Reminder{
+		NoteID:   note.ID,
+		UserID:   userID,
+		RemindAt: req.RemindAt,
+	}
+
+	if err := database.DB.Create(&reminder).Error; err != nil {
+		utils.LogError("Failed to create reminder: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create reminder",
+		})
+	}
+
+	utils.LogInfo(fmt.Sprintf("Reminder created: ID=%d, NoteID=%d, UserID=%d", reminder.ID, note.ID, userID))
+
+	return c.Status(fiber.StatusCreated).JSON(reminder)
+}
+
+// DeleteReminder removes a reminder from a note
+func DeleteReminder(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	var reminder models.Reminder
+	if err := database.DB.Where("id = ? AND note_id = ? AND user_id = ?", c.Params("reminderID"), c.Params("id"), userID).
+		First(&reminder).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Reminder not found",
+		})
+	}
+
+	if err := database.DB.Delete(&reminder).Error; err != nil {
+		utils.LogError("Failed to delete reminder: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete reminder",
+		})
+	}
+
+	utils.LogInfo(fmt.Sprintf("Reminder deleted: ID=%d, UserID=%d", reminder.ID, userID))
+
+	return c.JSON(fiber.Map{
+		"message": "Reminder deleted successfully",
+	})
+}
+
+// SnoozeReminder moves a reminder to a later time so it fires again
+func SnoozeReminder(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	var reminder models.Reminder
+	if err := database.DB.Where("id = ? AND note_id = ? AND user_id = ?", c.Params("reminderID"), c.Params("id"), userID).
+		First(&reminder).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Reminder not found",
+		})
+	}
+
+	var req models.SnoozeReminderRequest
+	if err := c.BodyParser(&req); err != nil {
+		utils.LogError("Failed to parse snooze reminder request: " + err.Error())
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	var until time.Time
+	switch {
+	case req.Until != nil:
+		until = *req.Until
+	case req.Minutes > 0:
+		until = time.Now().Add(time.Duration(req.Minutes) * time.Minute)
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Either until or a positive minutes value is required",
+		})
+	}
+
+	if !until.After(time.Now()) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Snooze time must be in the future",
+		})
+	}
+
+	// Clearing FiredAt re-arms the reminder for the scheduler
+	reminder.RemindAt = until
+	reminder.FiredAt = nil
+	if err := database.DB.Save(&reminder).Error; err != nil {
+		utils.LogError("Failed to snooze reminder: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to snooze reminder",
+		})
+	}
+
+	utils.LogInfo(fmt.Sprintf("Reminder snoozed: ID=%d, UserID=%d", reminder.ID, userID))
+
+	return c.JSON(reminder)
+}
+
+// parseTimeQuery parses an RFC 3339 timestamp or a YYYY-MM-DD date
+func parseTimeQuery(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
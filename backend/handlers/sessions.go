@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"fmt"
+	"notes-app/database"
+	"notes-app/models"
+	"notes-app/utils"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetSessions lists the devices the user is logged in on
+func GetSessions(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	claims := c.Locals("claims").(*utils.Claims)
+
+	var sessions []models.Session
+	err := database.DB.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").Find(&sessions).Error
+	if err != nil {
+		utils.LogError("Failed to get sessions: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve sessions",
+		})
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == claims.SessionID
+	}
+
+	return c.JSON(sessions)
+}
+
+// DeleteSession ends one of the user's sessions; its tokens stop working
+// immediately while other devices stay logged in
+func DeleteSession(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	sessionID := c.Params("id")
+
+	var session models.Session
+	if err := database.DB.Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).First(&session).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Session not found",
+		})
+	}
+
+	if err := revokeTokenFamily(database.DB, session.ID); err != nil {
+		utils.LogError("Failed to end session: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to end session",
+		})
+	}
+
+	utils.LogInfo(fmt.Sprintf("Session ended: ID=%s, UserID=%d", session.ID, userID))
+
+	return c.JSON(fiber.Map{
+		"message": "Session ended successfully",
+	})
+}
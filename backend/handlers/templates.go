@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"fmt"
+	"notes-app/database"
+	"notes-app/models"
+	"notes-app/utils"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetTemplates retrieves all note templates of the authenticated user
+func GetTemplates(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	var templates []models.NoteTemplate
+	if err := database.DB.Where("user_id = ?", userID).Order("name").Find(&templates).Error; err != nil {
+		utils.LogError("Failed to get templates: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve templates",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"templates": templates,
+	})
+}
+
+// GetTemplate retrieves a specific note template by ID
+func GetTemplate(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	templateID := c.Params("id")
+
+	var template models.NoteTemplate
+	if err := database.DB.Where("id = ? AND user_id = ?", templateID, userID).First(&template).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Template not found",
+		})
+	}
+
+	return c.JSON(template)
+}
+
+// CreateTemplate creates a new note template
+func CreateTemplate(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	var req models.TemplateRequest
+	if err := c.BodyParser(&req); err != nil {
+		utils.LogError("Failed to parse create template request: " + err.Error())
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	// Validate required fields
+	if req.Name == "" || req.Title == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Name and title are required",
+		})
+	}
+
+	template := models.NoteTemplate{
+		UserID:  userID,
+		Name:    req.Name,
+		Title:   req.Title,
+		Content: req.Content,
+	}
+
+	if err := database.DB.Create(&template).Error; err != nil {
+		utils.LogError("Failed to create template: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create template",
+		})
+	}
+
+	utils.LogInfo(fmt.Sprintf("Template created: ID=%d, UserID=%d", template.ID, userID))
+
+	return c.Status(fiber.StatusCreated).JSON(template)
+}
+
+// UpdateTemplate updates an existing note template
+func UpdateTemplate(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	templateID := c.Params("id")
+
+	var template models.NoteTemplate
+	if err := database.DB.Where("id = ? AND user_id = ?", templateID, userID).First(&template).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Template not found",
+		})
+	}
+
+	var req models.TemplateRequest
+	if err := c.BodyParser(&req); err != nil {
+		utils.LogError("Failed to parse update template request: " + err.Error())
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	// Update fields if provided
+	if req.Name != "" {
+		template.Name = req.Name
+	}
+	if req.Title != "" {
+		template.Title = req.Title
+	}
+	if req.Content != "" {
+		template.Content = req.Content
+	}
+
+	if err := database.DB.Save(&template).Error; err != nil {
+		utils.LogError("Failed to update template: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update template",
+		})
+	}
+
+	utils.LogInfo(fmt.Sprintf("Template updated: ID=%d, UserID=%d", template.ID, userID))
+
+	return c.JSON(template)
+}
+
+// DeleteTemplate deletes a note template
+func DeleteTemplate(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	templateID := c.Params("id")
+
+	var template models.NoteTemplate
+	if err := database.DB.Where("id = ? AND user_id = ?", templateID, userID).First(&template).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Template not found",
+		})
+	}
+
+	if err := database.DB.Delete(&template).Error; err != nil {
+		utils.LogError("Failed to delete template: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete template",
+		})
+	}
+
+	utils.LogInfo(fmt.Sprintf("Template deleted: ID=%d, UserID=%d", template.ID, userID))
+
+	return c.JSON(fiber.Map{
+		"message": "Template deleted successfully",
+	})
+}
+
+// CreateNoteFromTemplate creates a note by filling in a template's placeholders
+func CreateNoteFromTemplate(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	templateID := c.Params("templateID")
+
+	var template models.NoteTemplate
+	if err := database.DB.Where("id = ? AND user_id = ?", templateID, userID).First(&template).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Template not found",
+		})
+	}
+
+	var req models.CreateNoteFromTemplateRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			utils.LogError("Failed to parse create note from template request: " + err.Error())
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	// Custom fields can't override the built-in variables
+	vars := make(map[string]string, len(req.Fields)+5)
+	for name, value := range req.Fields {
+		vars[name] = value
+	}
+	now := time.Now()
+	vars["date"] = now.Format("2006-01-02")
+	vars["time"] = now.Format("15:04")
+	vars["datetime"] = now.Format("2006-01-02 15:04")
+	vars["user.name"] = user.Name
+	vars["user.email"] = user.Email
+
+	title, _ := utils.RenderTemplate(template.Title, vars)
+	content, _ := utils.RenderTemplate(template.Content, vars)
+	if _, missing := utils.RenderTemplate(template.Title+"\n"+template.Content, vars); len(missing) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Missing template fields: " + strings.Join(missing, ", "),
+		})
+	}
+
+	// Validate required fields
+	if title == "" || content == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Title and content are required",
+		})
+	}
+
+	return createNote(c, &models.Note{
+		UserID:  userID,
+		Title:   title,
+		Content: content,
+	})
+}
@@ -0,0 +1,354 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"notes-app/config"
+	"notes-app/database"
+	"notes-app/models"
+	"notes-app/revocation"
+	"notes-app/utils"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errInvalidRefreshToken = errors.New("invalid refresh token")
+	errRefreshTokenRotated = errors.New("refresh token was just rotated")
+)
+
+// refreshReuseGrace is how long after rotation a refresh token may be
+// presented again without counting as reuse. Browser tabs share one refresh
+// token, so two of them may refresh at the same time; the later one gets
+// nothing and picks up the new tokens stored by the other tab.
+const refreshReuseGrace = 10 * time.Second
+
+// Refresh exchanges a refresh token for a new access token. The refresh
+// token is rotated on every use; presenting one that was already rotated
+// revokes its whole family, logging out both the thief and the victim.
+func Refresh(c *fiber.Ctx) error {
+	var req models.RefreshRequest
+	if err := c.BodyParser(&req); err != nil || req.RefreshToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Refresh token is required",
+		})
+	}
+
+	var user models.User
+	var accessToken, refreshToken string
+	reused := false
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// The row lock makes concurrent use of the same token count as reuse
+		var current models.RefreshToken
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", utils.HashToken(req.RefreshToken)).First(&current).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errInvalidRefreshToken
+		}
+		if err != nil {
+			return err
+		}
+
+		// A token revoked by logout is merely invalid; one that was rotated
+		// has been copied
+		if current.RevokedAt != nil && current.ReplacedByID == nil {
+			return errInvalidRefreshToken
+		}
+		if current.RevokedAt != nil && time.Since(*current.RevokedAt) < refreshReuseGrace {
+			return errRefreshTokenRotated
+		}
+		if current.RevokedAt != nil {
+			reused = true
+			user.ID = current.UserID
+			return revokeTokenFamily(tx, current.FamilyID)
+		}
+		if time.Now().After(current.ExpiresAt) {
+			return errInvalidRefreshToken
+		}
+
+		if err := tx.First(&user, current.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errInvalidRefreshToken
+			}
+			return err
+		}
+
+		var next *models.RefreshToken
+		accessToken, refreshToken, next, err = issueTokens(tx, &user, current.FamilyID)
+		if err != nil {
+			return err
+		}
+
+		err = tx.Model(&current).Updates(map[string]interface{}{
+			"revoked_at":     time.Now(),
+			"replaced_by_id": next.ID,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&models.Session{}).Where("id = ?", current.FamilyID).Updates(map[string]interface{}{
+			"ip_address":   c.IP(),
+			"last_seen_at": time.Now(),
+			"expires_at":   next.ExpiresAt,
+		}).Error
+	})
+
+	if reused {
+		utils.LogWarning(fmt.Sprintf("Refresh token reuse detected, token family revoked: UserID=%d", user.ID))
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Refresh token has already been used, please log in again",
+		})
+	}
+	if errors.Is(err, errInvalidRefreshToken) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid or expired refresh token",
+		})
+	}
+	if errors.Is(err, errRefreshTokenRotated) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":                 "Refresh token has just been rotated, use the new one",
+			"refresh_token_rotated": true,
+		})
+	}
+	if err != nil {
+		utils.LogError("Failed to refresh token: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to refresh token",
+		})
+	}
+
+	return c.JSON(tokenResponse("Token refreshed", accessToken, refreshToken, &user))
+}
+
+// Logout revokes the access token used for the request and, if given, the
+// refresh token family it belongs to
+func Logout(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	claims := c.Locals("claims").(*utils.Claims)
+
+	var req models.LogoutRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+	}
+
+	if err := revocation.Tokens.Revoke(claims.ID, claims.ExpiresAt.Time); err != nil {
+		utils.LogError("Failed to revoke token: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to log out",
+		})
+	}
+
+	if claims.SessionID != "" {
+		if err := revokeTokenFamily(database.DB, claims.SessionID); err != nil {
+			utils.LogError("Failed to end session: " + err.Error())
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to log out",
+			})
+		}
+	}
+
+	if req.RefreshToken != "" {
+		var stored models.RefreshToken
+		err := database.DB.Where("token_hash = ? AND user_id = ?", utils.HashToken(req.RefreshToken), userID).First(&stored).Error
+		if err == nil {
+			err = revokeTokenFamily(database.DB, stored.FamilyID)
+		}
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.LogError("Failed to revoke refresh token: " + err.Error())
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to log out",
+			})
+		}
+	}
+
+	utils.LogInfo(fmt.Sprintf("User logged out: UserID=%d", userID))
+
+	return c.JSON(fiber.Map{
+		"message": "Logged out successfully",
+	})
+}
+
+// LogoutAll invalidates every access and refresh token of the user by bumping
+// their token generation
+func LogoutAll(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.User{}).Where("id = ?", userID).
+			Update("token_generation", gorm.Expr("token_generation + 1")).Error
+		if err != nil {
+			return err
+		}
+		return revokeUserTokens(tx, userID)
+	})
+	if err != nil {
+		utils.LogError("Failed to log out everywhere: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to log out",
+		})
+	}
+
+	utils.LogInfo(fmt.Sprintf("User logged out everywhere: UserID=%d", userID))
+
+	return c.JSON(fiber.Map{
+		"message": "Logged out on all devices",
+	})
+}
+
+// startSession records a new session for the user on the requesting device
+// and issues its first access and refresh tokens
+func startSession(c *fiber.Ctx, user *models.User) (string, string, error) {
+	id, err := utils.GenerateSecureToken(16)
+	if err != nil {
+		return "", "", err
+	}
+
+	var accessToken, refreshToken string
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		session := models.Session{
+			ID:         id,
+			UserID:     user.ID,
+			UserAgent:  c.Get(fiber.HeaderUserAgent),
+			IPAddress:  c.IP(),
+			ExpiresAt:  time.Now().Add(config.LoadConfig().RefreshTokenTTL),
+			LastSeenAt: time.Now(),
+		}
+		if err := tx.Create(&session).Error; err != nil {
+			return err
+		}
+
+		accessToken, refreshToken, _, err = issueTokens(tx, user, id)
+		return err
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	return accessToken, refreshToken, nil
+}
+
+// finishLogin completes a login whose first factor was verified: accounts
+// with 2FA get a challenge for the second factor, others a new session
+func finishLogin(c *fiber.Ctx, user *models.User, logMessage string) error {
+	if user.TwoFactorEnabled {
+		challenge, ttl, err := startTwoFactorChallenge(user)
+		if err != nil {
+			utils.LogError("Failed to create two-factor challenge: " + err.Error())
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to generate token",
+			})
+		}
+
+		return c.JSON(fiber.Map{
+			"message":             "Two-factor authentication required",
+			"two_factor_required": true,
+			"challenge_token":     challenge,
+			"expires_in":          int(ttl.Seconds()),
+		})
+	}
+
+	// Start a session with an access token and its refresh token
+	token, refreshToken, err := startSession(c, user)
+	if err != nil {
+		utils.LogError("Failed to generate token: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to generate token",
+		})
+	}
+
+	utils.LogInfo(logMessage + user.Email)
+
+	return c.JSON(tokenResponse("Login successful", token, refreshToken, user))
+}
+
+// issueTokens creates an access token and a refresh token in the given family
+func issueTokens(db *gorm.DB, user *models.User, familyID string) (string, string, *models.RefreshToken, error) {
+	accessToken, err := utils.GenerateToken(user.ID, user.Email, user.TokenGeneration, familyID)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	refreshToken, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	stored := models.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: utils.HashToken(refreshToken),
+		ExpiresAt: time.Now().Add(config.LoadConfig().RefreshTokenTTL),
+	}
+	if err := db.Create(&stored).Error; err != nil {
+		return "", "", nil, err
+	}
+
+	return accessToken, refreshToken, &stored, nil
+}
+
+// revokeTokenFamily ends a session, revoking every live refresh token of its family
+func revokeTokenFamily(db *gorm.DB, familyID string) error {
+	now := time.Now()
+	err := db.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", now).Error
+	if err != nil {
+		return err
+	}
+	return db.Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", now).Error
+}
+
+// revokeUserTokens ends every session of a user, revoking all their refresh
+// tokens, and deletes their personal access tokens
+func revokeUserTokens(db *gorm.DB, userID uint) error {
+	now := time.Now()
+	err := db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", now).Error
+	if err != nil {
+		return err
+	}
+	err = db.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", now).Error
+	if err != nil {
+		return err
+	}
+	return deletePersonalAccessTokens(db, userID)
+}
+
+// revokeOtherSessions ends every session of a user except keepSessionID
+func revokeOtherSessions(db *gorm.DB, userID uint, keepSessionID string) error {
+	now := time.Now()
+	err := db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND family_id <> ? AND revoked_at IS NULL", userID, keepSessionID).
+		Update("revoked_at", now).Error
+	if err != nil {
+		return err
+	}
+	return db.Model(&models.Session{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, keepSessionID).
+		Update("revoked_at", now).Error
+}
+
+// tokenResponse builds the response body returned when tokens are issued
+func tokenResponse(message, accessToken, refreshToken string, user *models.User) fiber.Map {
+	return fiber.Map{
+		"message":       message,
+		"token":         accessToken,
+		"refresh_token": refreshToken,
+		"expires_in":    int(config.LoadConfig().AccessTokenTTL.Seconds()),
+		"user":          user,
+	}
+}
@@ -0,0 +1,478 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"notes-app/config"
+	"notes-app/database"
+	"notes-app/models"
+	"notes-app/utils"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// recoveryCodeCount is how many recovery codes a user gets at a time
+	recoveryCodeCount = 10
+	// maxChallengeAttempts limits guesses of the second factor per login
+	maxChallengeAttempts = 5
+	// maxSecondFactorFailures wrong codes in a row, across all logins and
+	// account settings, lock the second factor for secondFactorLockout
+	maxSecondFactorFailures = 10
+	secondFactorLockout     = 15 * time.Minute
+)
+
+var (
+	errInvalidChallenge = errors.New("invalid two-factor challenge")
+	errTwoFactorLocked  = errors.New("too many invalid two-factor codes")
+)
+
+// EnrollTwoFactor starts 2FA enrollment by generating a TOTP secret. 2FA is
+// only enabled once a code from the authenticator app is confirmed.
+func EnrollTwoFactor(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	var req models.EnrollTwoFactorRequest
+	if err := c.BodyParser(&req); err != nil || req.Password == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Password is required",
+		})
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	if err := user.CheckPassword(req.Password); err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Password is incorrect",
+		})
+	}
+
+	if user.TwoFactorEnabled {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Two-factor authentication is already enabled",
+		})
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		utils.LogError("Failed to generate TOTP secret: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to start two-factor enrollment",
+		})
+	}
+
+	if err := database.DB.Model(&user).Update("totp_secret", secret).Error; err != nil {
+		utils.LogError("Failed to save TOTP secret: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to start two-factor enrollment",
+		})
+	}
+
+	// The URI is what the QR code shown to the user should encode
+	return c.JSON(fiber.Map{
+		"secret":      secret,
+		"otpauth_uri": utils.TOTPURI(config.LoadConfig().TwoFactorIssuer, user.Email, secret),
+	})
+}
+
+// ConfirmTwoFactor enables 2FA once the user proves their authenticator app
+// produces valid codes, and returns a fresh set of recovery codes
+func ConfirmTwoFactor(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	var req models.TwoFactorCodeRequest
+	if err := c.BodyParser(&req); err != nil || req.Code == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Code is required",
+		})
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	if user.TwoFactorEnabled {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Two-factor authentication is already enabled",
+		})
+	}
+	if user.TOTPSecret == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Start two-factor enrollment first",
+		})
+	}
+
+	step, ok := utils.ValidateTOTP(*user.TOTPSecret, strings.TrimSpace(req.Code), time.Now())
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid code",
+		})
+	}
+
+	var codes []string
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&user).Updates(map[string]interface{}{
+			"two_factor_enabled": true,
+			"totp_last_step":     step,
+		}).Error
+		if err != nil {
+			return err
+		}
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		utils.LogError("Failed to enable two-factor authentication: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to enable two-factor authentication",
+		})
+	}
+
+	utils.LogInfo(fmt.Sprintf("Two-factor authentication enabled: UserID=%d", user.ID))
+
+	return c.JSON(fiber.Map{
+		"message":        "Two-factor authentication enabled",
+		"recovery_codes": codes,
+	})
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes with new ones
+func RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	var req models.TwoFactorCodeRequest
+	if err := c.BodyParser(&req); err != nil || req.Code == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Code is required",
+		})
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	if !user.TwoFactorEnabled {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Two-factor authentication is not enabled",
+		})
+	}
+
+	ok, err := checkSecondFactor(database.DB, &user, req.Code)
+	if errors.Is(err, errTwoFactorLocked) {
+		return twoFactorLocked(c)
+	}
+	if err != nil {
+		utils.LogError("Failed to verify two-factor code: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to verify code",
+		})
+	}
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid code",
+		})
+	}
+
+	codes, err := replaceRecoveryCodes(database.DB, user.ID)
+	if err != nil {
+		utils.LogError("Failed to generate recovery codes: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to generate recovery codes",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"recovery_codes": codes,
+	})
+}
+
+// DisableTwoFactor turns 2FA off; it needs both the password and a code
+func DisableTwoFactor(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	var req models.DisableTwoFactorRequest
+	if err := c.BodyParser(&req); err != nil || req.Password == "" || req.Code == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Password and code are required",
+		})
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	if !user.TwoFactorEnabled {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Two-factor authentication is not enabled",
+		})
+	}
+
+	if err := user.CheckPassword(req.Password); err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Password is incorrect",
+		})
+	}
+
+	ok, err := checkSecondFactor(database.DB, &user, req.Code)
+	if errors.Is(err, errTwoFactorLocked) {
+		return twoFactorLocked(c)
+	}
+	if err != nil {
+		utils.LogError("Failed to verify two-factor code: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to verify code",
+		})
+	}
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid code",
+		})
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&user).Updates(map[string]interface{}{
+			"two_factor_enabled": false,
+			"totp_secret":        nil,
+			"totp_last_step":     0,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error
+	})
+	if err != nil {
+		utils.LogError("Failed to disable two-factor authentication: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to disable two-factor authentication",
+		})
+	}
+
+	utils.LogInfo(fmt.Sprintf("Two-factor authentication disabled: UserID=%d", user.ID))
+
+	return c.JSON(fiber.Map{
+		"message": "Two-factor authentication disabled",
+	})
+}
+
+// VerifyTwoFactor completes a password login for an account with 2FA,
+// exchanging the challenge token and a TOTP or recovery code for tokens
+func VerifyTwoFactor(c *fiber.Ctx) error {
+	var req models.VerifyTwoFactorRequest
+	if err := c.BodyParser(&req); err != nil || req.ChallengeToken == "" || req.Code == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Challenge token and code are required",
+		})
+	}
+
+	var user models.User
+	verified := false
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var challenge models.TwoFactorChallenge
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", utils.HashToken(req.ChallengeToken)).First(&challenge).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errInvalidChallenge
+		}
+		if err != nil {
+			return err
+		}
+		if time.Now().After(challenge.ExpiresAt) || challenge.Attempts >= maxChallengeAttempts {
+			return errInvalidChallenge
+		}
+
+		if err := tx.First(&user, challenge.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errInvalidChallenge
+			}
+			return err
+		}
+
+		verified, err = checkSecondFactor(tx, &user, req.Code)
+		if err != nil {
+			return err
+		}
+		if !verified {
+			// Commit the failed attempt so guesses are limited per challenge
+			return tx.Model(&challenge).Update("attempts", gorm.Expr("attempts + 1")).Error
+		}
+		return tx.Delete(&challenge).Error
+	})
+
+	if errors.Is(err, errInvalidChallenge) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Login has expired, please log in again",
+		})
+	}
+	if errors.Is(err, errTwoFactorLocked) {
+		return twoFactorLocked(c)
+	}
+	if err != nil {
+		utils.LogError("Failed to verify two-factor login: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to verify code",
+		})
+	}
+	if !verified {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid code",
+		})
+	}
+
+	token, refreshToken, err := startSession(c, &user)
+	if err != nil {
+		utils.LogError("Failed to generate token: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to generate token",
+		})
+	}
+
+	utils.LogInfo("User logged in with two-factor authentication: " + user.Email)
+
+	return c.JSON(tokenResponse("Login successful", token, refreshToken, &user))
+}
+
+// startTwoFactorChallenge issues the challenge token a password login
+// returns instead of tokens when the account has 2FA enabled
+func startTwoFactorChallenge(user *models.User) (string, time.Duration, error) {
+	token, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		return "", 0, err
+	}
+
+	ttl := config.LoadConfig().TwoFactorChallengeTTL
+	challenge := models.TwoFactorChallenge{
+		UserID:    user.ID,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := database.DB.Create(&challenge).Error; err != nil {
+		return "", 0, err
+	}
+	return token, ttl, nil
+}
+
+// checkSecondFactor verifies a code with verifySecondFactor, counting wrong
+// codes per user. After maxSecondFactorFailures in a row no codes are checked
+// for secondFactorLockout, which keeps the 6-digit codes from being guessed.
+func checkSecondFactor(db *gorm.DB, user *models.User, code string) (bool, error) {
+	verified := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// The row lock keeps concurrent guesses from slipping past the count
+		var state models.User
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "two_factor_failures", "two_factor_locked_until").First(&state, user.ID).Error
+		if err != nil {
+			return err
+		}
+		if state.TwoFactorLockedUntil != nil && time.Now().Before(*state.TwoFactorLockedUntil) {
+			return errTwoFactorLocked
+		}
+
+		verified, err = verifySecondFactor(tx, user, code)
+		if err != nil {
+			return err
+		}
+		if verified {
+			if state.TwoFactorFailures == 0 && state.TwoFactorLockedUntil == nil {
+				return nil
+			}
+			return tx.Model(&state).Updates(map[string]interface{}{
+				"two_factor_failures":     0,
+				"two_factor_locked_until": nil,
+			}).Error
+		}
+
+		if state.TwoFactorFailures+1 < maxSecondFactorFailures {
+			return tx.Model(&state).Update("two_factor_failures", state.TwoFactorFailures+1).Error
+		}
+		utils.LogWarning(fmt.Sprintf("Two-factor authentication locked after %d invalid codes: UserID=%d",
+			maxSecondFactorFailures, user.ID))
+		return tx.Model(&state).Updates(map[string]interface{}{
+			"two_factor_failures":     0,
+			"two_factor_locked_until": time.Now().Add(secondFactorLockout),
+		}).Error
+	})
+	return verified, err
+}
+
+// twoFactorLocked answers a request while the user's second factor is locked
+func twoFactorLocked(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+		"error": "Too many invalid codes, please try again later",
+	})
+}
+
+// verifySecondFactor checks a TOTP code or an unused recovery code. Both are
+// consumed on success: a TOTP code can't be replayed within its time window,
+// and a recovery code works only once.
+func verifySecondFactor(db *gorm.DB, user *models.User, code string) (bool, error) {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+
+	if user.TOTPSecret != nil {
+		if step, ok := utils.ValidateTOTP(*user.TOTPSecret, code, time.Now()); ok {
+			result := db.Model(&models.User{}).
+				Where("id = ? AND totp_last_step < ?", user.ID, step).
+				Update("totp_last_step", step)
+			return result.RowsAffected == 1, result.Error
+		}
+	}
+
+	result := db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, utils.HashToken(normalizeRecoveryCode(code))).
+		Update("used_at", time.Now())
+	if result.RowsAffected == 1 {
+		utils.LogInfo(fmt.Sprintf("Recovery code used: UserID=%d", user.ID))
+	}
+	return result.RowsAffected == 1, result.Error
+}
+
+// replaceRecoveryCodes deletes the user's recovery codes and generates new
+// ones, returning them in plain text to be shown once
+func replaceRecoveryCodes(db *gorm.DB, userID uint) ([]string, error) {
+	if err := db.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	codes := make([]string, recoveryCodeCount)
+	rows := make([]models.RecoveryCode, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		raw := strings.ToLower(encoding.EncodeToString(b))[:10]
+		codes[i] = raw[:5] + "-" + raw[5:]
+		rows[i] = models.RecoveryCode{UserID: userID, CodeHash: utils.HashToken(raw)}
+	}
+
+	if err := db.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// normalizeRecoveryCode strips formatting so codes can be typed loosely
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(code, "-", ""))
+}
@@ -0,0 +1,457 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"notes-app/blobs"
+	"notes-app/config"
+	"notes-app/database"
+	"notes-app/models"
+	"notes-app/storage"
+	"notes-app/utils"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Resumable uploads: the client creates a session with the file's size and
+// SHA-256, then PATCHes chunks in order, each starting at the current offset
+// (sent in the Upload-Offset header). After an interrupted request the client
+// asks for the offset and continues from there. The chunk that completes the
+// file triggers assembly: the checksum is verified and the file is attached
+// to the note.
+//
+// Each chunk is stored as its own object in the storage backend, keyed by
+// the upload ID and the chunk's offset, so any backend instance can take the
+// next chunk. Assembly streams the chunks from storage in offset order, and
+// they are deleted once the upload is completed or aborted.
+
+var checksumPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+var (
+	errOffsetMismatch = errors.New("upload offset mismatch")
+	errChunkTooLarge  = errors.New("chunk exceeds upload size")
+	errUploadGone     = errors.New("upload session no longer exists")
+	errChunksMissing  = errors.New("upload chunks missing from storage")
+)
+
+// CreateUpload starts a resumable attachment upload
+func CreateUpload(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	noteID := c.Params("id")
+
+	var note models.Note
+	if err := database.DB.Where("id = ? AND user_id = ?", noteID, userID).First(&note).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Note not found",
+		})
+	}
+
+	var req models.CreateUploadRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	req.Checksum = strings.ToLower(strings.TrimSpace(req.Checksum))
+	if strings.TrimSpace(req.Filename) == "" || req.Size <= 0 || !checksumPattern.MatchString(req.Checksum) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Filename, size and a hex encoded SHA-256 checksum are required",
+		})
+	}
+
+	cfg := config.LoadConfig()
+	if req.Size > cfg.MaxResumableUploadBytes {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+			"error": fmt.Sprintf("File is too large, maximum size is %d bytes", cfg.MaxResumableUploadBytes),
+		})
+	}
+
+	if ok, err := checkQuota(c, userID, req.Size, 0); !ok {
+		return err
+	}
+
+	id, err := utils.GenerateSecureToken(16)
+	if err != nil {
+		utils.LogError("Failed to generate upload ID: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create upload",
+		})
+	}
+
+	session := models.UploadSession{
+		ID:        id,
+		NoteID:    note.ID,
+		UserID:    userID,
+		Filename:  sanitizeFilename(req.Filename),
+		Size:      req.Size,
+		Checksum:  req.Checksum,
+		ExpiresAt: time.Now().Add(cfg.UploadSessionExpiry),
+	}
+
+	if err := database.DB.Create(&session).Error; err != nil {
+		utils.LogError("Failed to create upload session: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create upload",
+		})
+	}
+
+	utils.LogInfo(fmt.Sprintf("Upload started: ID=%s, NoteID=%d, UserID=%d, Size=%d", session.ID, note.ID, userID, session.Size))
+
+	c.Location(fmt.Sprintf("/api/notes/%d/uploads/%s", note.ID, session.ID))
+	c.Set("Upload-Offset", "0")
+	return c.Status(fiber.StatusCreated).JSON(session)
+}
+
+// GetUpload reports how much of an upload has been received, so an
+// interrupted client knows where to resume
+func GetUpload(c *fiber.Ctx) error {
+	session, err := findUpload(c)
+	if session == nil {
+		return err
+	}
+
+	c.Set("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+	return c.JSON(session)
+}
+
+// UploadChunk appends a chunk at the offset given in the Upload-Offset header.
+// The chunk that completes the file assembles it into an attachment.
+func UploadChunk(c *fiber.Ctx) error {
+	session, err := findUpload(c)
+	if session == nil {
+		return err
+	}
+
+	offset, err := strconv.ParseInt(c.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "A valid Upload-Offset header is required",
+		})
+	}
+
+	chunk := c.Body()
+
+	// The row lock keeps concurrent requests for the same upload from
+	// writing over each other
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", session.ID).First(session).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errUploadGone
+			}
+			return err
+		}
+		if offset != session.Offset {
+			return errOffsetMismatch
+		}
+		if offset+int64(len(chunk)) > session.Size {
+			return errChunkTooLarge
+		}
+		if len(chunk) == 0 {
+			return nil
+		}
+
+		// A chunk stored before a failed offset update is overwritten when
+		// the client sends it again
+		if err := storage.Store.Put(session.ChunkKey(offset), bytes.NewReader(chunk), int64(len(chunk)), "application/octet-stream"); err != nil {
+			return err
+		}
+
+		session.Offset = offset + int64(len(chunk))
+		return tx.Model(session).Update("upload_offset", session.Offset).Error
+	})
+
+	switch {
+	case errors.Is(err, errUploadGone):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Upload not found",
+		})
+	case errors.Is(err, errOffsetMismatch):
+		c.Set("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":  fmt.Sprintf("Upload-Offset does not match, the upload is at offset %d", session.Offset),
+			"offset": session.Offset,
+		})
+	case errors.Is(err, errChunkTooLarge):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Chunk goes past the declared upload size",
+		})
+	case err != nil:
+		utils.LogError("Failed to write upload chunk: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to save chunk",
+		})
+	}
+
+	c.Set("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+	if session.Offset < session.Size {
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+
+	// An empty chunk at the final offset retries a failed assembly
+	return completeUpload(c, session)
+}
+
+// DeleteUpload aborts an upload and discards the received data
+func DeleteUpload(c *fiber.Ctx) error {
+	session, err := findUpload(c)
+	if session == nil {
+		return err
+	}
+
+	if err := discardUpload(session); err != nil {
+		utils.LogError("Failed to delete upload: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete upload",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Upload deleted successfully",
+	})
+}
+
+// completeUpload verifies a fully received upload and attaches it to the note
+func completeUpload(c *fiber.Ctx, session *models.UploadSession) error {
+	cfg := config.LoadConfig()
+
+	chunks, err := uploadChunks(session)
+	if errors.Is(err, errChunksMissing) {
+		// The received data can't be put back together, the client has to start over
+		discardUpload(session)
+		utils.LogError(fmt.Sprintf("Upload chunks missing from storage: ID=%s, UserID=%d", session.ID, session.UserID))
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Upload data is missing, start the upload again",
+		})
+	}
+	if err != nil {
+		utils.LogError("Failed to list upload chunks: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to assemble upload",
+		})
+	}
+
+	part := &chunkReader{keys: chunks}
+	defer part.Close()
+
+	// Detect the MIME type from the content and check it against the allowlist
+	head := make([]byte, 512)
+	n, err := io.ReadFull(part, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		utils.LogError("Failed to read upload chunks: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to assemble upload",
+		})
+	}
+	head = head[:n]
+
+	mimeType := utils.DetectMIME(head, session.Filename)
+	if !mimeAllowed(mimeType, cfg.AttachmentMIMETypes) {
+		discardUpload(session)
+		return c.Status(fiber.StatusUnsupportedMediaType).JSON(fiber.Map{
+			"error": fmt.Sprintf("File type %s is not allowed", mimeType),
+		})
+	}
+
+	hasher := sha256.New()
+	hasher.Write(head)
+	if _, err := io.Copy(hasher, part); err != nil {
+		utils.LogError("Failed to read upload chunks: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to assemble upload",
+		})
+	}
+	checksum := hex.EncodeToString(hasher.Sum(nil))
+
+	// A corrupted upload can't be repaired by resuming, the client has to start over
+	if checksum != session.Checksum {
+		discardUpload(session)
+		utils.LogInfo(fmt.Sprintf("Upload checksum mismatch: ID=%s, UserID=%d", session.ID, session.UserID))
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error": "Checksum mismatch, the upload has been discarded",
+		})
+	}
+
+	key, err := blobs.Acquire(checksum, session.Size, mimeType, func() (io.ReadCloser, error) {
+		return &chunkReader{keys: chunks}, nil
+	})
+	if err != nil {
+		utils.LogError("Failed to save attachment: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to save attachment",
+		})
+	}
+
+	attachment := models.Attachment{
+		NoteID:     session.NoteID,
+		UserID:     session.UserID,
+		Filename:   session.Filename,
+		MimeType:   mimeType,
+		Size:       session.Size,
+		StorageKey: key,
+		Checksum:   checksum,
+	}
+
+	// Deleting the session claims it, so a repeated final request can't
+	// attach the same upload twice. Quota may have been used up by other
+	// uploads since this one started; then the session is kept so the
+	// client can retry after freeing space.
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := reserveQuota(tx, session.UserID, session.Size, 0); err != nil {
+			return err
+		}
+		var note models.Note
+		if err := tx.Where("id = ? AND user_id = ?", session.NoteID, session.UserID).First(&note).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errUploadGone
+			}
+			return err
+		}
+		result := tx.Delete(session)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errUploadGone
+		}
+		return tx.Create(&attachment).Error
+	})
+	if err != nil {
+		releaseFiles(key)
+		if errors.Is(err, errQuotaExceeded) {
+			return quotaExceeded(c, session.UserID, session.Size)
+		}
+		if errors.Is(err, errUploadGone) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Upload not found",
+			})
+		}
+		utils.LogError("Failed to create attachment: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to save attachment",
+		})
+	}
+
+	part.Close()
+	deleteUploadChunks(session)
+
+	utils.LogInfo(fmt.Sprintf("Attachment uploaded: ID=%d, NoteID=%d, UserID=%d, UploadID=%s", attachment.ID, session.NoteID, session.UserID, session.ID))
+
+	return c.Status(fiber.StatusCreated).JSON(attachment)
+}
+
+// findUpload loads the upload session named in the route, writing a 404
+// response and returning nil if the user doesn't own it
+func findUpload(c *fiber.Ctx) (*models.UploadSession, error) {
+	userID := c.Locals("userID").(uint)
+
+	var session models.UploadSession
+	err := database.DB.Where("id = ? AND note_id = ? AND user_id = ? AND expires_at > ?",
+		c.Params("uploadID"), c.Params("id"), userID, time.Now()).First(&session).Error
+	if err != nil {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Upload not found",
+		})
+	}
+	return &session, nil
+}
+
+// discardUpload deletes an upload session and its chunks
+func discardUpload(session *models.UploadSession) error {
+	if err := database.DB.Delete(session).Error; err != nil {
+		return err
+	}
+	deleteUploadChunks(session)
+	return nil
+}
+
+// deleteUploadChunks deletes the stored chunks of an upload whose session is
+// gone, logging failures; leftovers are found by the cleanup-uploads command
+func deleteUploadChunks(session *models.UploadSession) {
+	if err := storage.DeletePrefix(session.ChunkPrefix()); err != nil {
+		utils.LogError(fmt.Sprintf("Failed to delete chunks of upload %s: %v", session.ID, err))
+	}
+}
+
+// uploadChunks returns the storage keys of a fully received upload's chunks
+// in offset order, or errChunksMissing if they don't make up the whole file
+func uploadChunks(session *models.UploadSession) ([]string, error) {
+	var objects []storage.Object
+	err := storage.Store.List(session.ChunkPrefix(), func(obj storage.Object) error {
+		objects = append(objects, obj)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+
+	keys := make([]string, 0, len(objects))
+	var offset int64
+	for _, obj := range objects {
+		if obj.Key != session.ChunkKey(offset) {
+			return nil, errChunksMissing
+		}
+		keys = append(keys, obj.Key)
+		offset += obj.Size
+	}
+	if offset != session.Size {
+		return nil, errChunksMissing
+	}
+	return keys, nil
+}
+
+// chunkReader reads stored chunks one after another, opening each one only
+// when the previous one has been read
+type chunkReader struct {
+	keys    []string
+	current io.ReadCloser
+}
+
+// Read reads from the current chunk, moving on to the next one at its end
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if len(r.keys) == 0 {
+				return 0, io.EOF
+			}
+			chunk, err := storage.Store.Get(r.keys[0])
+			if err != nil {
+				return 0, err
+			}
+			r.current, r.keys = chunk, r.keys[1:]
+		}
+
+		n, err := r.current.Read(p)
+		if err == io.EOF {
+			r.current.Close()
+			r.current = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+// Close closes the chunk being read
+func (r *chunkReader) Close() error {
+	if r.current == nil {
+		return nil
+	}
+	err := r.current.Close()
+	r.current = nil
+	return err
+}
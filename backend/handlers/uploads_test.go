@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"notes-app/models"
+	"notes-app/storage"
+	"testing"
+)
+
+func useTestStorage(t *testing.T) {
+	previous := storage.Store
+	storage.Store = storage.NewLocalStorage(t.TempDir(), "/api/files", []byte("test"))
+	t.Cleanup(func() { storage.Store = previous })
+}
+
+func putChunk(t *testing.T, key string, data []byte) {
+	t.Helper()
+	if err := storage.Store.Put(key, bytes.NewReader(data), int64(len(data)), "application/octet-stream"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUploadChunksAssembleInOffsetOrder(t *testing.T) {
+	useTestStorage(t)
+
+	// More than ten chunks, so lexical and numeric order would differ without padding
+	var want []byte
+	session := &models.UploadSession{ID: "upload-1"}
+	for i := 0; i < 12; i++ {
+		chunk := bytes.Repeat([]byte{byte('a' + i)}, i+1)
+		putChunk(t, session.ChunkKey(int64(len(want))), chunk)
+		want = append(want, chunk...)
+	}
+	session.Size = int64(len(want))
+
+	// Another upload's chunks aren't picked up
+	other := &models.UploadSession{ID: "upload-10"}
+	putChunk(t, other.ChunkKey(0), []byte("other"))
+
+	keys, err := uploadChunks(session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &chunkReader{keys: keys}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+	if !bytes.Equal(got, want) {
+		t.Fatalf("assembled %q, want %q", got, want)
+	}
+
+	deleteUploadChunks(session)
+	if _, err := uploadChunks(session); !errors.Is(err, errChunksMissing) {
+		t.Fatalf("chunks left after delete: %v", err)
+	}
+	if keys, err := uploadChunks(&models.UploadSession{ID: "upload-10", Size: 5}); err != nil || len(keys) != 1 {
+		t.Fatalf("other upload's chunks affected: %v, %v", keys, err)
+	}
+}
+
+func TestUploadChunksMissing(t *testing.T) {
+	useTestStorage(t)
+	session := &models.UploadSession{ID: "upload-1", Size: 9}
+
+	tests := []struct {
+		name   string
+		chunks map[int64]string
+	}{
+		{"none", nil},
+		{"gap", map[int64]string{0: "abc", 6: "ghi"}},
+		{"short", map[int64]string{0: "abc", 3: "def"}},
+		{"overlap", map[int64]string{0: "abc", 2: "cdefghi"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deleteUploadChunks(session)
+			for offset, data := range tt.chunks {
+				putChunk(t, session.ChunkKey(offset), []byte(data))
+			}
+			if _, err := uploadChunks(session); !errors.Is(err, errChunksMissing) {
+				t.Fatalf("got %v, want errChunksMissing", err)
+			}
+		})
+	}
+}
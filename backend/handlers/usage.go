@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"notes-app/config"
+	"notes-app/database"
+	"notes-app/models"
+	"notes-app/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errQuotaExceeded = errors.New("storage quota exceeded")
+
+// GetUsage reports the authenticated user's note count, attachment count and storage use
+func GetUsage(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	var noteCount, attachmentCount int64
+	if err := database.DB.Model(&models.Note{}).Where("user_id = ?", userID).Count(&noteCount).Error; err != nil {
+		utils.LogError("Failed to count notes: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve usage",
+		})
+	}
+	if err := database.DB.Model(&models.Attachment{}).Where("user_id = ?", userID).Count(&attachmentCount).Error; err != nil {
+		utils.LogError("Failed to count attachments: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve usage",
+		})
+	}
+
+	used, err := storageUsage(database.DB, userID)
+	if err != nil {
+		utils.LogError("Failed to compute storage usage: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve usage",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"notes":       noteCount,
+		"attachments": attachmentCount,
+		"bytes_used":  used,
+		"quota_bytes": config.LoadConfig().StorageQuotaBytes,
+	})
+}
+
+// storageUsage returns the bytes stored for a user's note images and attachments
+func storageUsage(db *gorm.DB, userID uint) (int64, error) {
+	var images, attachments int64
+	if err := db.Model(&models.Note{}).Where("user_id = ?", userID).
+		Select("COALESCE(SUM(image_bytes), 0)").Scan(&images).Error; err != nil {
+		return 0, err
+	}
+	if err := db.Model(&models.Attachment{}).Where("user_id = ?", userID).
+		Select("COALESCE(SUM(size), 0)").Scan(&attachments).Error; err != nil {
+		return 0, err
+	}
+	return images + attachments, nil
+}
+
+// checkQuota writes a 413 response and returns false when storing additional
+// bytes (after freeing freed bytes) would take the user over their quota.
+// It rejects uploads early, before their data is read; reserveQuota makes
+// the final check when the upload is saved.
+func checkQuota(c *fiber.Ctx, userID uint, additional, freed int64) (bool, error) {
+	used, err := storageUsage(database.DB, userID)
+	if err != nil {
+		utils.LogError("Failed to compute storage usage: " + err.Error())
+		return false, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to check storage quota",
+		})
+	}
+
+	if used-freed+additional > config.LoadConfig().StorageQuotaBytes {
+		return false, quotaExceeded(c, userID, additional)
+	}
+	return true, nil
+}
+
+// lockStorage locks the user's row until tx ends, so concurrent uploads by
+// the same user check and use their quota one after another
+func lockStorage(tx *gorm.DB, userID uint) error {
+	var user models.User
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&user, userID).Error
+}
+
+// reserveQuota returns errQuotaExceeded when storing additional bytes (after
+// freeing freed bytes) would take the user over their quota. It holds the
+// user's storage lock, so the caller must create the rows that use the
+// storage in the same transaction.
+func reserveQuota(tx *gorm.DB, userID uint, additional, freed int64) error {
+	if err := lockStorage(tx, userID); err != nil {
+		return err
+	}
+	used, err := storageUsage(tx, userID)
+	if err != nil {
+		return err
+	}
+	if used-freed+additional > config.LoadConfig().StorageQuotaBytes {
+		return errQuotaExceeded
+	}
+	return nil
+}
+
+// quotaExceeded writes the 413 response for an upload of additional bytes
+// that doesn't fit in the user's quota
+func quotaExceeded(c *fiber.Ctx, userID uint, additional int64) error {
+	used, err := storageUsage(database.DB, userID)
+	if err != nil {
+		utils.LogError("Failed to compute storage usage: " + err.Error())
+	}
+	quota := config.LoadConfig().StorageQuotaBytes
+	return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+		"error":       fmt.Sprintf("Storage quota exceeded: %d of %d bytes used, this upload needs %d more", used, quota, additional),
+		"bytes_used":  used,
+		"quota_bytes": quota,
+	})
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"fmt"
+	"net/url"
+	"notes-app/config"
+	"notes-app/database"
+	"notes-app/mailer"
+	"notes-app/models"
+	"notes-app/utils"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// VerifyEmail marks the user's email address as verified using the signed
+// token from the verification link
+func VerifyEmail(c *fiber.Ctx) error {
+	var req models.VerifyEmailRequest
+	if err := c.BodyParser(&req); err != nil || req.Token == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Token is required",
+		})
+	}
+
+	claims, err := utils.ValidateEmailToken(req.Token, utils.EmailTokenVerify)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid or expired verification link",
+		})
+	}
+
+	// The token names the address it was sent to, so it stops working if
+	// the email has changed since
+	var user models.User
+	if err := database.DB.Where("id = ? AND email = ?", claims.UserID, claims.Email).First(&user).Error; err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid or expired verification link",
+		})
+	}
+
+	if user.EmailVerifiedAt == nil {
+		now := time.Now()
+		if err := database.DB.Model(&user).Update("email_verified_at", now).Error; err != nil {
+			utils.LogError("Failed to verify email: " + err.Error())
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to verify email",
+			})
+		}
+		user.EmailVerifiedAt = &now
+		utils.LogInfo("Email verified: " + user.Email)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Email verified successfully",
+		"user":    user,
+	})
+}
+
+// ResendVerification sends a new verification link to the current user
+func ResendVerification(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	if user.EmailVerifiedAt != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Email is already verified",
+		})
+	}
+
+	if err := sendVerificationEmail(&user); err != nil {
+		utils.LogError("Failed to send verification email: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to send verification email",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Verification email sent",
+	})
+}
+
+// sendVerificationEmail emails the user a link to verify their address
+func sendVerificationEmail(user *models.User) error {
+	cfg := config.LoadConfig()
+
+	token, err := utils.GenerateEmailToken(utils.EmailClaims{
+		UserID:  user.ID,
+		Email:   user.Email,
+		Purpose: utils.EmailTokenVerify,
+	}, cfg.EmailVerificationExpiry)
+	if err != nil {
+		return err
+	}
+
+	link := cfg.AppURL + "/verify-email?token=" + url.QueryEscape(token)
+	err = mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Please confirm that this is your email address by opening this link:\n\n%s\n\n"+
+			"The link expires in %s. If you didn't create a Notes account, you can ignore this email.\n",
+			user.Name, link, cfg.EmailVerificationExpiry),
+	})
+	if err != nil {
+		return err
+	}
+
+	utils.LogInfo(fmt.Sprintf("Verification email sent: UserID=%d", user.ID))
+	return nil
+}
@@ -0,0 +1,262 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+)
+
+// EXIF orientation values (TIFF tag 0x0112)
+const (
+	OrientationNormal  = 1
+	exifOrientationTag = 0x0112
+)
+
+// StripMetadata removes EXIF, XMP, IPTC and text metadata from JPEG and PNG
+// images. Images with a non-default EXIF orientation are rotated and
+// re-encoded so they still display the right way up; all others are
+// stripped losslessly, or re-encoded if their layout can't be walked
+// safely. Other formats are returned unchanged.
+func StripMetadata(data []byte, info *Info) ([]byte, error) {
+	if info.Format != FormatJPEG && info.Format != FormatPNG {
+		return data, nil
+	}
+
+	orientation := Orientation(data, info.Format)
+	if orientation == OrientationNormal {
+		var stripped []byte
+		var ok bool
+		if info.Format == FormatJPEG {
+			stripped, ok = stripJPEG(data)
+		} else {
+			stripped, ok = stripPNG(data)
+		}
+		if ok {
+			return stripped, nil
+		}
+	}
+
+	// Decoding drops every kind of metadata, so re-encoding is always safe
+	return reencode(data, info.Format, orientation)
+}
+
+// reencode decodes an image and encodes the pixels again, upright for the
+// given EXIF orientation and without any metadata
+func reencode(data []byte, format string, orientation int) ([]byte, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	img = ApplyOrientation(img, orientation)
+
+	var buf bytes.Buffer
+	if format == FormatJPEG {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 92})
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Orientation returns the EXIF orientation of a JPEG or PNG image, or
+// OrientationNormal when it has none
+func Orientation(data []byte, format string) int {
+	var tiff []byte
+	switch format {
+	case FormatJPEG:
+		tiff = jpegEXIF(data)
+	case FormatPNG:
+		tiff = pngChunk(data, "eXIf")
+	}
+	if o := tiffOrientation(tiff); o >= 1 && o <= 8 {
+		return o
+	}
+	return OrientationNormal
+}
+
+// ApplyOrientation transforms img so that it displays upright for the given
+// EXIF orientation value
+func ApplyOrientation(img image.Image, orientation int) image.Image {
+	if orientation <= OrientationNormal || orientation > 8 {
+		return img
+	}
+
+	b := img.Bounds()
+	src := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirrored horizontally
+				dx, dy = w-1-x, y
+			case 3: // rotated 180°
+				dx, dy = w-1-x, h-1-y
+			case 4: // mirrored vertically
+				dx, dy = x, h-1-y
+			case 5: // transposed
+				dx, dy = y, x
+			case 6: // rotated 90° clockwise
+				dx, dy = h-1-y, x
+			case 7: // transversed
+				dx, dy = h-1-y, w-1-x
+			case 8: // rotated 90° counter-clockwise
+				dx, dy = y, w-1-x
+			}
+			copy(dst.Pix[dst.PixOffset(dx, dy):dst.PixOffset(dx, dy)+4], src.Pix[src.PixOffset(x, y):src.PixOffset(x, y)+4])
+		}
+	}
+
+	return dst
+}
+
+// stripJPEG drops APP1 (EXIF/XMP), APP13 (IPTC) and comment segments.
+// APP0 (JFIF), APP2 (ICC profile) and APP14 (Adobe) are kept as they
+// affect how the image is rendered. It reports false for a layout it
+// doesn't understand, so metadata is never passed through by accident.
+func stripJPEG(data []byte) ([]byte, bool) {
+	if len(data) < 2 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, false
+	}
+
+	var out bytes.Buffer
+	out.Write(data[:2]) // SOI
+
+	i := 2
+	for i+4 <= len(data) {
+		if data[i] != 0xFF {
+			return nil, false
+		}
+		marker := data[i+1]
+		if marker == 0xDA {
+			// Start of scan: the rest is image data
+			out.Write(data[i:])
+			return out.Bytes(), true
+		}
+
+		length := int(binary.BigEndian.Uint16(data[i+2 : i+4]))
+		end := i + 2 + length
+		if length < 2 || end > len(data) {
+			return nil, false
+		}
+
+		if marker != 0xE1 && marker != 0xED && marker != 0xFE {
+			out.Write(data[i:end])
+		}
+		i = end
+	}
+
+	return nil, false
+}
+
+// stripPNG drops text, time and EXIF chunks. It reports false for a layout
+// it doesn't understand, so metadata is never passed through by accident.
+func stripPNG(data []byte) ([]byte, bool) {
+	if len(data) < 8 {
+		return nil, false
+	}
+
+	var out bytes.Buffer
+	out.Write(data[:8]) // signature
+
+	for i := 8; i+12 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[i : i+4]))
+		end := i + 12 + length
+		if length < 0 || end < i || end > len(data) {
+			return nil, false
+		}
+
+		chunkType := string(data[i+4 : i+8])
+		switch chunkType {
+		case "tEXt", "zTXt", "iTXt", "eXIf", "tIME":
+		default:
+			out.Write(data[i:end])
+		}
+		if chunkType == "IEND" {
+			return out.Bytes(), true
+		}
+		i = end
+	}
+
+	return nil, false
+}
+
+// jpegEXIF returns the TIFF payload of a JPEG's EXIF APP1 segment
+func jpegEXIF(data []byte) []byte {
+	for i := 2; i+4 <= len(data) && data[i] == 0xFF && data[i+1] != 0xDA; {
+		length := int(binary.BigEndian.Uint16(data[i+2 : i+4]))
+		end := i + 2 + length
+		if length < 2 || end > len(data) {
+			return nil
+		}
+		segment := data[i+4 : end]
+		if data[i+1] == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return segment[6:]
+		}
+		i = end
+	}
+	return nil
+}
+
+// pngChunk returns the data of the first PNG chunk of the given type
+func pngChunk(data []byte, chunkType string) []byte {
+	for i := 8; i+12 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[i : i+4]))
+		end := i + 12 + length
+		if length < 0 || end > len(data) {
+			return nil
+		}
+		if string(data[i+4:i+8]) == chunkType {
+			return data[i+8 : i+8+length]
+		}
+		i = end
+	}
+	return nil
+}
+
+// tiffOrientation reads the orientation tag from IFD0 of a TIFF structure
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 0
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+
+	offset := int(order.Uint32(tiff[4:8]))
+	if offset+2 > len(tiff) {
+		return 0
+	}
+	count := int(order.Uint16(tiff[offset : offset+2]))
+	for n := 0; n < count; n++ {
+		entry := offset + 2 + n*12
+		if entry+12 > len(tiff) {
+			return 0
+		}
+		if order.Uint16(tiff[entry:entry+2]) == exifOrientationTag {
+			return int(order.Uint16(tiff[entry+8 : entry+10]))
+		}
+	}
+	return 0
+}
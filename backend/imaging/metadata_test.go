@@ -0,0 +1,131 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// gpsMarker stands in for EXIF GPS data; it must never survive stripping
+var gpsMarker = []byte("Exif\x00\x00GPS 52.5200N 13.4050E")
+
+func testImage() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for i := range img.Pix {
+		img.Pix[i] = byte(i)
+	}
+	img.Set(0, 0, color.RGBA{255, 0, 0, 255})
+	return img
+}
+
+// jpegWithEXIF encodes a JPEG and inserts an APP1 segment after SOI
+func jpegWithEXIF(t *testing.T) []byte {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testImage(), nil); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	app1 := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(app1[2:], uint16(len(gpsMarker)+2))
+	app1 = append(app1, gpsMarker...)
+	return append(append(append([]byte{}, data[:2]...), app1...), data[2:]...)
+}
+
+// pngChunkBytes builds a PNG chunk with a valid CRC
+func pngChunkBytes(chunkType string, payload []byte) []byte {
+	chunk := make([]byte, 4, 12+len(payload))
+	binary.BigEndian.PutUint32(chunk, uint32(len(payload)))
+	chunk = append(chunk, chunkType...)
+	chunk = append(chunk, payload...)
+	crc := crc32.ChecksumIEEE(chunk[4:])
+	return binary.BigEndian.AppendUint32(chunk, crc)
+}
+
+// pngWithEXIF encodes a PNG and inserts an eXIf chunk after IHDR
+func pngWithEXIF(t *testing.T) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testImage()); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	ihdrEnd := 8 + 12 + int(binary.BigEndian.Uint32(data[8:12]))
+	exif := pngChunkBytes("eXIf", gpsMarker)
+	return append(append(append([]byte{}, data[:ihdrEnd]...), exif...), data[ihdrEnd:]...)
+}
+
+func TestStripMetadata(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		data   func(t *testing.T) []byte
+	}{
+		{"jpeg", FormatJPEG, jpegWithEXIF},
+		{"png", FormatPNG, pngWithEXIF},
+		{"jpeg with garbage between segments", FormatJPEG, func(t *testing.T) []byte {
+			// A stray byte after the EXIF segment breaks the segment walk
+			data := jpegWithEXIF(t)
+			at := 2 + 4 + len(gpsMarker)
+			return append(append(append([]byte{}, data[:at]...), 0x00), data[at:]...)
+		}},
+		{"jpeg with segment running past the end", FormatJPEG, func(t *testing.T) []byte {
+			data := jpegWithEXIF(t)
+			at := 2 + 4 + len(gpsMarker)
+			binary.BigEndian.PutUint16(data[at+2:], 0xFFFF)
+			return data
+		}},
+		{"png with trailing chunk after IEND is ignored", FormatPNG, func(t *testing.T) []byte {
+			return append(pngWithEXIF(t), pngChunkBytes("tEXt", gpsMarker)...)
+		}},
+		{"png truncated inside a chunk", FormatPNG, func(t *testing.T) []byte {
+			data := pngWithEXIF(t)
+			// Claim a huge IDAT so the walk can't find IEND
+			idat := bytes.Index(data, []byte("IDAT")) - 4
+			binary.BigEndian.PutUint32(data[idat:], 1<<30)
+			return data
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tt.data(t)
+			if !bytes.Contains(data, gpsMarker) {
+				t.Fatal("test image has no metadata")
+			}
+
+			out, err := StripMetadata(data, &Info{Format: tt.format})
+			if err != nil {
+				// Refusing an image is acceptable, keeping its metadata is not
+				t.Logf("rejected: %v", err)
+				return
+			}
+			if bytes.Contains(out, []byte("GPS 52.5200N")) {
+				t.Fatal("metadata survived stripping")
+			}
+			if _, _, err := image.Decode(bytes.NewReader(out)); err != nil {
+				t.Fatalf("stripped image doesn't decode: %v", err)
+			}
+		})
+	}
+}
+
+func TestStripMetadataKeepsCleanImageLossless(t *testing.T) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testImage(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := StripMetadata(buf.Bytes(), &Info{Format: FormatJPEG})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, buf.Bytes()) {
+		t.Error("image without metadata was re-encoded")
+	}
+}
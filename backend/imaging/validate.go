@@ -0,0 +1,117 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"  // register GIF decoder
+	_ "image/jpeg" // register JPEG decoder
+	_ "image/png"  // register PNG decoder
+	"path/filepath"
+	"strings"
+
+	_ "golang.org/x/image/webp" // register WebP decoder
+)
+
+// Supported image formats, as reported by image.DecodeConfig
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+	FormatGIF  = "gif"
+	FormatWebP = "webp"
+)
+
+// Limits restricts the size of accepted images
+type Limits struct {
+	MaxBytes  int64
+	MaxWidth  int
+	MaxHeight int
+}
+
+// Info describes a validated image
+type Info struct {
+	Format string
+	MIME   string
+	Ext    string
+	Width  int
+	Height int
+}
+
+// ErrTooLarge is returned when the image exceeds Limits.MaxBytes
+var ErrTooLarge = errors.New("image file is too large")
+
+// formatInfo holds the MIME type and accepted extensions of each format;
+// the first extension is the canonical one used for stored files
+var formatInfo = map[string]struct {
+	mime string
+	exts []string
+}{
+	FormatJPEG: {"image/jpeg", []string{".jpg", ".jpeg"}},
+	FormatPNG:  {"image/png", []string{".png"}},
+	FormatGIF:  {"image/gif", []string{".gif"}},
+	FormatWebP: {"image/webp", []string{".webp"}},
+}
+
+// Validate checks that data is a JPEG, PNG, GIF or WebP image within limits,
+// judging by its content rather than its name. The filename's extension
+// (case-insensitive) must agree with the detected format.
+func Validate(data []byte, filename string, limits Limits) (*Info, error) {
+	if limits.MaxBytes > 0 && int64(len(data)) > limits.MaxBytes {
+		return nil, fmt.Errorf("%w: maximum size is %d bytes", ErrTooLarge, limits.MaxBytes)
+	}
+
+	format := sniffFormat(data)
+	if format == "" {
+		return nil, errors.New("file is not a JPEG, PNG, GIF or WebP image")
+	}
+	info := formatInfo[format]
+
+	ext := strings.ToLower(filepath.Ext(filename))
+	if !contains(info.exts, ext) {
+		return nil, fmt.Errorf("file content is %s but the file name ends in %q", strings.ToUpper(format), filepath.Ext(filename))
+	}
+
+	// Decoding the header confirms the file is well-formed and gives its dimensions
+	cfg, decoded, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || decoded != format {
+		return nil, fmt.Errorf("file is not a valid %s image", strings.ToUpper(format))
+	}
+
+	if (limits.MaxWidth > 0 && cfg.Width > limits.MaxWidth) || (limits.MaxHeight > 0 && cfg.Height > limits.MaxHeight) {
+		return nil, fmt.Errorf("image is %dx%d pixels, maximum is %dx%d", cfg.Width, cfg.Height, limits.MaxWidth, limits.MaxHeight)
+	}
+
+	return &Info{
+		Format: format,
+		MIME:   info.mime,
+		Ext:    info.exts[0],
+		Width:  cfg.Width,
+		Height: cfg.Height,
+	}, nil
+}
+
+// sniffFormat detects the image format from the file's magic bytes
+func sniffFormat(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8, 0xFF}):
+		return FormatJPEG
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return FormatPNG
+	case bytes.HasPrefix(data, []byte("GIF87a")), bytes.HasPrefix(data, []byte("GIF89a")):
+		return FormatGIF
+	case len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return FormatWebP
+	}
+	return ""
+}
+
+// contains reports whether s is in list
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
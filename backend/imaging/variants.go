@@ -0,0 +1,103 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"image/png"
+
+	"golang.org/x/image/draw"
+)
+
+// Variant sizes, as the longest edge in pixels
+const (
+	ThumbnailSize = 320
+	MediumSize    = 1024
+)
+
+// Variant is a resized rendition of an uploaded image
+type Variant struct {
+	Name   string
+	Data   []byte
+	MIME   string
+	Ext    string
+	Width  int
+	Height int
+}
+
+// GenerateVariants decodes an image validated by Validate and renders the
+// thumbnail and medium variants. JPEG sources produce JPEG variants; PNG,
+// GIF and WebP sources produce PNG variants so transparency is kept.
+func GenerateVariants(data []byte, info *Info) ([]Variant, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	src = ApplyOrientation(src, Orientation(data, info.Format))
+
+	variants := make([]Variant, 0, 2)
+	for _, v := range []struct {
+		name string
+		size int
+	}{
+		{"thumbnail", ThumbnailSize},
+		{"medium", MediumSize},
+	} {
+		variant, err := renderVariant(src, v.name, v.size, info.Format == FormatJPEG)
+		if err != nil {
+			return nil, err
+		}
+		variants = append(variants, *variant)
+	}
+
+	return variants, nil
+}
+
+// renderVariant scales src to fit within size x size and encodes the result
+func renderVariant(src image.Image, name string, size int, asJPEG bool) (*Variant, error) {
+	img := Fit(src, size)
+
+	var buf bytes.Buffer
+	variant := &Variant{
+		Name:   name,
+		Width:  img.Bounds().Dx(),
+		Height: img.Bounds().Dy(),
+	}
+
+	if asJPEG {
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85}); err != nil {
+			return nil, err
+		}
+		variant.MIME, variant.Ext = "image/jpeg", ".jpg"
+	} else {
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, err
+		}
+		variant.MIME, variant.Ext = "image/png", ".png"
+	}
+
+	variant.Data = buf.Bytes()
+	return variant, nil
+}
+
+// Fit scales img down so that neither side exceeds size, keeping the aspect
+// ratio. Images that already fit are returned unchanged.
+func Fit(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= size && h <= size {
+		return img
+	}
+
+	if w >= h {
+		h = max(1, h*size/w)
+		w = size
+	} else {
+		w = max(1, w*size/h)
+		h = size
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst
+}
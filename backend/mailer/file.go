@@ -0,0 +1,41 @@
+package mailer
+
+import (
+	"fmt"
+	"notes-app/utils"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileMailer writes each email as an .eml file to a directory instead of
+// sending it, for local development
+type FileMailer struct {
+	dir  string
+	from string
+}
+
+// NewFileMailer creates a FileMailer writing to dir
+func NewFileMailer(dir, from string) *FileMailer {
+	return &FileMailer{dir: dir, from: from}
+}
+
+// Send implements Mailer
+func (m *FileMailer) Send(msg Message) error {
+	if err := os.MkdirAll(m.dir, 0755); err != nil {
+		return err
+	}
+
+	suffix, err := utils.GenerateSecureToken(6)
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405"), suffix)
+
+	if err := os.WriteFile(filepath.Join(m.dir, name), formatMessage(m.from, msg), 0600); err != nil {
+		return err
+	}
+
+	utils.LogInfo(fmt.Sprintf("Email to %s written to %s", msg.To, filepath.Join(m.dir, name)))
+	return nil
+}
@@ -0,0 +1,45 @@
+package mailer
+
+import (
+	"fmt"
+	"notes-app/config"
+)
+
+// Message is a plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers emails
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// Default is the mailer selected in the configuration
+var Default Mailer
+
+// Setup initializes Default from the configuration
+func Setup(cfg *config.Config) error {
+	switch cfg.MailBackend {
+	case "file":
+		Default = NewFileMailer(cfg.MailDir, cfg.MailFrom)
+	case "smtp":
+		Default = NewSMTPMailer(SMTPOptions{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.MailFrom,
+		})
+	default:
+		return fmt.Errorf("mailer: unknown backend %q", cfg.MailBackend)
+	}
+	return nil
+}
+
+// Send delivers msg with the Default mailer
+func Send(msg Message) error {
+	return Default.Send(msg)
+}
@@ -1,32 +1,73 @@
 package middleware
 
 import (
+	"errors"
+	"notes-app/database"
+	"notes-app/models"
+	"notes-app/revocation"
 	"notes-app/utils"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-// AuthMiddleware validates JWT token from Authorization header
+// AuthMiddleware validates the JWT access token of a login session from the
+// Authorization header. Personal access tokens are refused: only routes that
+// authenticate with RequireScope accept them.
 func AuthMiddleware(c *fiber.Ctx) error {
-	// Get Authorization header
-	authHeader := c.Get("Authorization")
-	if authHeader == "" {
+	tokenString, err := bearerToken(c.Get("Authorization"))
+	if err != nil {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "Missing authorization header",
+			"error": err.Error(),
 		})
 	}
 
+	if strings.HasPrefix(tokenString, models.PersonalAccessTokenPrefix) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Personal access tokens can't be used here",
+		})
+	}
+
+	return authenticateSession(c, tokenString)
+}
+
+// RequireScope authenticates a route personal access tokens may use: a
+// personal access token is accepted only if it was given scope, the access
+// token of a login session always is
+func RequireScope(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		tokenString, err := bearerToken(c.Get("Authorization"))
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		if strings.HasPrefix(tokenString, models.PersonalAccessTokenPrefix) {
+			return authenticatePersonalAccessToken(c, tokenString, scope)
+		}
+		return authenticateSession(c, tokenString)
+	}
+}
+
+// bearerToken extracts the token from an Authorization header
+func bearerToken(authHeader string) (string, error) {
+	if authHeader == "" {
+		return "", errors.New("Missing authorization header")
+	}
+
 	// Check if it starts with "Bearer "
 	if !strings.HasPrefix(authHeader, "Bearer ") {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "Invalid authorization header format",
-		})
+		return "", errors.New("Invalid authorization header format")
 	}
 
 	// Extract token
-	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	return strings.TrimPrefix(authHeader, "Bearer "), nil
+}
 
+// authenticateSession validates the JWT access token of a login session
+func authenticateSession(c *fiber.Ctx, tokenString string) error {
 	// Validate token
 	claims, err := utils.ValidateToken(tokenString)
 	if err != nil {
@@ -35,9 +76,102 @@ func AuthMiddleware(c *fiber.Ctx) error {
 		})
 	}
 
+	// Reject tokens revoked by logout, or issued before "log out everywhere"
+	if claims.ID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid or expired token",
+		})
+	}
+
+	revoked, err := revocation.Tokens.IsRevoked(claims.ID)
+	if err != nil {
+		utils.LogError("Failed to check token revocation: " + err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to validate token",
+		})
+	}
+
+	var user models.User
+	if revoked || database.DB.Select("id", "token_generation").First(&user, claims.UserID).Error != nil ||
+		user.TokenGeneration != claims.Generation {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Token has been revoked",
+		})
+	}
+
+	// Tokens of an ended session stop working immediately
+	if claims.SessionID != "" {
+		var session models.Session
+		err := database.DB.Where("id = ? AND user_id = ?", claims.SessionID, claims.UserID).First(&session).Error
+		if err != nil || session.RevokedAt != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Session has ended",
+			})
+		}
+
+		// Record activity, at most once a minute per session
+		if time.Since(session.LastSeenAt) > time.Minute {
+			database.DB.Model(&session).Update("last_seen_at", time.Now())
+		}
+	}
+
 	// Store user ID in context for use in handlers
 	c.Locals("userID", claims.UserID)
 	c.Locals("email", claims.Email)
+	c.Locals("claims", claims)
+
+	return c.Next()
+}
+
+// authenticatePersonalAccessToken validates a personal access token and
+// checks it was given scope
+func authenticatePersonalAccessToken(c *fiber.Ctx, tokenString, scope string) error {
+	var token models.PersonalAccessToken
+	err := database.DB.Where("token_hash = ?", utils.HashToken(tokenString)).First(&token).Error
+	if err != nil || (token.ExpiresAt != nil && time.Now().After(*token.ExpiresAt)) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid or expired token",
+		})
+	}
 
+	if !token.HasScope(scope) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Token lacks the " + scope + " scope",
+		})
+	}
+
+	var user models.User
+	if err := database.DB.Select("id", "email").First(&user, token.UserID).Error; err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid or expired token",
+		})
+	}
+
+	// Record usage, at most once a minute per token
+	if token.LastUsedAt == nil || time.Since(*token.LastUsedAt) > time.Minute {
+		database.DB.Model(&token).Update("last_used_at", time.Now())
+	}
+
+	c.Locals("userID", user.ID)
+	c.Locals("email", user.Email)
+	c.Locals("personalAccessToken", &token)
+
+	return c.Next()
+}
+
+// RequireVerifiedEmail rejects users who haven't verified their email
+// address yet, for features that reach beyond the user's own account
+func RequireVerifiedEmail(c *fiber.Ctx) error {
+	var user models.User
+	if err := database.DB.Select("id", "email_verified_at").First(&user, c.Locals("userID")).Error; err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+	if user.EmailVerifiedAt == nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Please verify your email address first",
+		})
+	}
 	return c.Next()
 }
\ No newline at end of file
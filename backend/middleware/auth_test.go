@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"notes-app/models"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestAuthMiddlewareRefusesPersonalAccessTokens(t *testing.T) {
+	app := fiber.New()
+	reached := false
+	app.Get("/", AuthMiddleware, func(c *fiber.Ctx) error {
+		reached = true
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	tests := []struct {
+		header string
+		status int
+	}{
+		{"", fiber.StatusUnauthorized},
+		{"Basic dXNlcjpwYXNz", fiber.StatusUnauthorized},
+		{"Bearer " + models.PersonalAccessTokenPrefix + "secret", fiber.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(fiber.MethodGet, "/", nil)
+		if tt.header != "" {
+			req.Header.Set("Authorization", tt.header)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tt.status {
+			t.Errorf("Authorization %q: got status %d, want %d", tt.header, resp.StatusCode, tt.status)
+		}
+	}
+	if reached {
+		t.Fatal("handler reached without a login session")
+	}
+}
+
+func TestRequireScopeNeedsAuthorization(t *testing.T) {
+	app := fiber.New()
+	app.Get("/", RequireScope(models.ScopeNotesRead), func(c *fiber.Ctx) error {
+		t.Fatal("handler reached without authorization")
+		return nil
+	})
+
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusUnauthorized {
+		t.Fatalf("got status %d, want %d", resp.StatusCode, fiber.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// Attachment represents a file attached to a note
+type Attachment struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	NoteID     uint      `gorm:"not null;index" json:"note_id"`
+	UserID     uint      `gorm:"not null;index" json:"user_id"`
+	Filename   string    `gorm:"not null" json:"filename"`
+	MimeType   string    `gorm:"not null" json:"mime_type"`
+	Size       int64     `gorm:"not null" json:"size"`
+	StorageKey string    `gorm:"not null" json:"-"`
+	Checksum   string    `gorm:"not null" json:"checksum"` // SHA-256, hex encoded
+	CreatedAt  time.Time `json:"created_at"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// Blob represents a stored file addressed by the SHA-256 of its content.
+// RefCount is the number of note images and attachments using it; the blob
+// is deleted when it drops to zero.
+type Blob struct {
+	Hash      string    `gorm:"primaryKey;size:64" json:"hash"`
+	Size      int64     `gorm:"not null" json:"size"`
+	MimeType  string    `gorm:"not null" json:"mime_type"`
+	RefCount  int       `gorm:"not null" json:"ref_count"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
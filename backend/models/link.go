@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// NoteLink represents a wiki-style link from one note to another.
+// TargetNoteID is nil while a [[Title]] link does not match any note yet.
+type NoteLink struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	SourceNoteID uint      `gorm:"not null;index" json:"source_note_id"`
+	TargetNoteID *uint     `gorm:"index" json:"target_note_id"`
+	TargetTitle  string    `json:"target_title"`
+	CreatedAt    time.Time `json:"created_at"`
+}
@@ -8,25 +8,43 @@ import (
 
 // Note represents a note in the system
 type Note struct {
-	ID        uint           `gorm:"primaryKey" json:"id"`
-	UserID    uint           `gorm:"not null;index" json:"user_id"`
-	User      User           `gorm:"foreignKey:UserID" json:"user,omitempty"`
-	Title     string         `gorm:"not null" json:"title"`
-	Content   string         `gorm:"type:text" json:"content"`
-	ImageURL  string         `json:"image_url,omitempty"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	ID                uint           `gorm:"primaryKey" json:"id"`
+	UserID            uint           `gorm:"not null;index" json:"user_id"`
+	User              User           `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Title             string         `gorm:"not null" json:"title"`
+	Content           string         `gorm:"type:text" json:"content"`
+	ImageKey          string         `json:"-"`
+	ImageMediumKey    string         `json:"-"`
+	ImageThumbnailKey string         `json:"-"`
+	ImageBytes        int64          `gorm:"not null;default:0" json:"-"` // total size of the original and its variants
+	Images            *NoteImages    `gorm:"-" json:"images,omitempty"` // resolved from the image keys per response
+	DueAt             *time.Time     `gorm:"index" json:"due_at,omitempty"`
+	Reminders         []Reminder     `gorm:"foreignKey:NoteID" json:"reminders,omitempty"`
+	Attachments       []Attachment   `gorm:"foreignKey:NoteID" json:"attachments,omitempty"`
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// NoteImages holds the URLs of a note's image variants
+type NoteImages struct {
+	Original  string `json:"original"`
+	Medium    string `json:"medium"`
+	Thumbnail string `json:"thumbnail"`
 }
 
 // CreateNoteRequest represents the create note request payload
 type CreateNoteRequest struct {
-	Title   string `json:"title" validate:"required"`
-	Content string `json:"content" validate:"required"`
+	Title     string      `json:"title" validate:"required"`
+	Content   string      `json:"content" validate:"required"`
+	DueAt     *time.Time  `json:"due_at"`
+	Reminders []time.Time `json:"reminders"`
 }
 
 // UpdateNoteRequest represents the update note request payload
 type UpdateNoteRequest struct {
-	Title   string `json:"title"`
-	Content string `json:"content"`
-}
\ No newline at end of file
+	Title      string     `json:"title"`
+	Content    string     `json:"content"`
+	DueAt      *time.Time `json:"due_at"`
+	ClearDueAt bool       `json:"clear_due_at"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Notification represents an in-app notification for a user
+type Notification struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	NoteID    *uint      `gorm:"index" json:"note_id,omitempty"`
+	Type      string     `gorm:"not null" json:"type"`
+	Message   string     `gorm:"not null" json:"message"`
+	ReadAt    *time.Time `json:"read_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Notification types
+const (
+	NotificationTypeReminder = "reminder"
+)
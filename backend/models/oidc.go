@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// OIDCIdentity links a user to their account at an OpenID Connect provider
+type OIDCIdentity struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	UserID      uint       `gorm:"not null;index" json:"-"`
+	Provider    string     `gorm:"not null;uniqueIndex:idx_oidc_identities_provider_subject" json:"provider"`
+	Subject     string     `gorm:"not null;uniqueIndex:idx_oidc_identities_provider_subject" json:"-"` // the provider's user ID ("sub")
+	Email       string     `json:"email"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// OIDCLogin is a single sign-on login in progress, found again by its state
+// when the provider redirects back. Each login can be completed once, and
+// only by the browser that started it, which holds the browser secret.
+type OIDCLogin struct {
+	ID                uint      `gorm:"primaryKey"`
+	Provider          string    `gorm:"not null"`
+	StateHash         string    `gorm:"not null;uniqueIndex;size:64"` // SHA-256 of the state
+	BrowserSecretHash string    `gorm:"not null;default:'';size:64"`  // SHA-256 of the browser secret
+	Nonce             string    `gorm:"not null"`
+	CodeVerifier      string    `gorm:"not null"` // PKCE secret for the token request
+	ExpiresAt         time.Time `gorm:"not null;index"`
+}
+
+// OIDCCallbackRequest represents the request completing a single sign-on
+// login with the parameters the provider redirected back with
+type OIDCCallbackRequest struct {
+	State         string `json:"state"`
+	Code          string `json:"code"`
+	BrowserSecret string `json:"browser_secret"` // returned by the begin request, never part of a URL
+}
@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+// Passkey is a WebAuthn credential registered by a user for passwordless login
+type Passkey struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	UserID         uint       `gorm:"not null;index" json:"-"`
+	Name           string     `gorm:"not null" json:"name"`
+	CredentialID   string     `gorm:"not null;uniqueIndex" json:"credential_id"` // base64url encoded
+	PublicKey      []byte     `gorm:"not null" json:"-"`                         // COSE_Key encoding
+	SignCount      int64      `gorm:"not null;default:0" json:"-"`
+	BackupEligible bool       `gorm:"not null;default:false" json:"backup_eligible"` // synced between devices
+	LastUsedAt     *time.Time `json:"last_used_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// WebAuthnChallenge is a pending registration or login ceremony. Each
+// challenge can be answered once.
+type WebAuthnChallenge struct {
+	ID            uint      `gorm:"primaryKey"`
+	UserID        *uint     `gorm:"index"` // set for registration, nil for login
+	Ceremony      string    `gorm:"not null;size:16"`
+	ChallengeHash string    `gorm:"not null;uniqueIndex;size:64"` // SHA-256 of the base64url challenge
+	ExpiresAt     time.Time `gorm:"not null;index"`
+}
+
+// WebAuthn ceremonies
+const (
+	CeremonyRegistration   = "registration"
+	CeremonyAuthentication = "authentication"
+)
+
+// PublicKeyCredential is the JSON form of the browser's PublicKeyCredential,
+// with binary fields base64url encoded
+type PublicKeyCredential struct {
+	ID       string `json:"id"`
+	RawID    string `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON"`
+		AttestationObject string `json:"attestationObject"`
+		AuthenticatorData string `json:"authenticatorData"`
+		Signature         string `json:"signature"`
+		UserHandle        string `json:"userHandle"`
+	} `json:"response"`
+}
+
+// FinishPasskeyRegistrationRequest represents the request completing a passkey registration
+type FinishPasskeyRegistrationRequest struct {
+	Name       string              `json:"name"`
+	Credential PublicKeyCredential `json:"credential"`
+}
+
+// FinishPasskeyLoginRequest represents the request completing a passkey login
+type FinishPasskeyLoginRequest struct {
+	Credential PublicKeyCredential `json:"credential"`
+}
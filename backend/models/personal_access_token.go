@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+// PersonalAccessTokenPrefix starts every personal access token, telling them
+// apart from JWTs and making leaked tokens easy to search for
+const PersonalAccessTokenPrefix = "nat_"
+
+// Personal access token scopes
+const (
+	ScopeNotesRead        = "notes:read"
+	ScopeNotesWrite       = "notes:write"
+	ScopeAttachmentsWrite = "attachments:write"
+)
+
+// Scopes are all scopes a personal access token can be given
+var Scopes = []string{ScopeNotesRead, ScopeNotesWrite, ScopeAttachmentsWrite}
+
+// IsValidScope reports whether scope is one of Scopes
+func IsValidScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// PersonalAccessToken is a long-lived token a user creates for scripts and
+// API access. It only grants its scopes and is never tied to a session.
+type PersonalAccessToken struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uint       `gorm:"not null;index" json:"-"`
+	Name       string     `gorm:"not null" json:"name"`
+	TokenHash  string     `gorm:"not null;uniqueIndex;size:64" json:"-"` // SHA-256 of the token
+	Prefix     string     `gorm:"not null;size:16" json:"prefix"`        // start of the token, to recognize it
+	Scopes     []string   `gorm:"type:text;not null;serializer:json" json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"` // nil for a token that doesn't expire
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// HasScope reports whether the token grants scope
+func (t *PersonalAccessToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// CreatePersonalAccessTokenRequest represents the request creating a personal access token
+type CreatePersonalAccessTokenRequest struct {
+	Name      string     `json:"name" validate:"required"`
+	Scopes    []string   `json:"scopes" validate:"required"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
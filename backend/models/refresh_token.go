@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// RefreshToken is a long-lived token exchanged for new access tokens. Every
+// use rotates it: the token is revoked and replaced by a new one in the same
+// family, so a revoked token being presented again means it was stolen.
+type RefreshToken struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	UserID       uint       `gorm:"not null;index" json:"user_id"`
+	FamilyID     string     `gorm:"not null;index;size:32" json:"family_id"`
+	TokenHash    string     `gorm:"not null;uniqueIndex;size:64" json:"-"` // SHA-256 of the token
+	ExpiresAt    time.Time  `gorm:"not null;index" json:"expires_at"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	ReplacedByID *uint      `json:"replaced_by_id,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// RefreshRequest represents the refresh token request payload
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
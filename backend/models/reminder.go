@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Reminder represents a scheduled reminder for a note.
+// FiredAt is set once the scheduler has delivered the reminder.
+type Reminder struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	NoteID    uint       `gorm:"not null;index" json:"note_id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	RemindAt  time.Time  `gorm:"not null;index" json:"remind_at"`
+	FiredAt   *time.Time `gorm:"index" json:"fired_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// CreateReminderRequest represents the create reminder request payload
+type CreateReminderRequest struct {
+	RemindAt time.Time `json:"remind_at" validate:"required"`
+}
+
+// SnoozeReminderRequest represents the snooze reminder request payload.
+// Either Until or Minutes must be provided.
+type SnoozeReminderRequest struct {
+	Until   *time.Time `json:"until"`
+	Minutes int        `json:"minutes"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// RevokedToken records an access token (by its jti) revoked before it expired
+type RevokedToken struct {
+	JTI       string    `gorm:"primaryKey;size:32"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+}
+
+// LogoutRequest represents the logout request payload
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
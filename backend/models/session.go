@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// Session is a login on one device. Its ID is the family ID of the refresh
+// tokens issued for it, and access tokens carry it in their sid claim.
+type Session struct {
+	ID         string     `gorm:"primaryKey;size:32" json:"id"`
+	UserID     uint       `gorm:"not null;index" json:"-"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `gorm:"size:64" json:"ip_address"`
+	ExpiresAt  time.Time  `gorm:"not null;index" json:"expires_at"`
+	RevokedAt  *time.Time `json:"-"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Current    bool       `gorm:"-" json:"current"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// NoteTemplate represents a reusable note structure with {{placeholders}}
+type NoteTemplate struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	UserID    uint           `gorm:"not null;index" json:"user_id"`
+	Name      string         `gorm:"not null" json:"name"`
+	Title     string         `gorm:"not null" json:"title"`
+	Content   string         `gorm:"type:text" json:"content"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// TemplateRequest represents the create/update template request payload
+type TemplateRequest struct {
+	Name    string `json:"name" validate:"required"`
+	Title   string `json:"title" validate:"required"`
+	Content string `json:"content"`
+}
+
+// CreateNoteFromTemplateRequest represents the values for a template's custom fields
+type CreateNoteFromTemplateRequest struct {
+	Fields map[string]string `json:"fields"`
+}
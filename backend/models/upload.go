@@ -0,0 +1,43 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// UploadChunkPrefix is the storage prefix of the chunks of resumable uploads
+const UploadChunkPrefix = "chunks/"
+
+// UploadSession tracks a resumable attachment upload. Each received chunk is
+// stored as its own object in the storage backend until Offset reaches Size.
+type UploadSession struct {
+	ID        string    `gorm:"primaryKey;size:32" json:"id"`
+	NoteID    uint      `gorm:"not null;index" json:"note_id"`
+	UserID    uint      `gorm:"not null;index" json:"user_id"`
+	Filename  string    `gorm:"not null" json:"filename"`
+	Size      int64     `gorm:"not null" json:"size"`
+	Offset    int64     `gorm:"column:upload_offset;not null;default:0" json:"offset"`
+	Checksum  string    `gorm:"not null" json:"checksum"` // expected SHA-256, hex encoded
+	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ChunkPrefix returns the storage prefix of the upload's chunks. Chunks of
+// all uploads share one directory, so none is left behind empty.
+func (s *UploadSession) ChunkPrefix() string {
+	return UploadChunkPrefix + s.ID + "."
+}
+
+// ChunkKey returns the storage key of the chunk starting at offset. Offsets
+// are zero-padded so that the keys sort in offset order.
+func (s *UploadSession) ChunkKey(offset int64) string {
+	return fmt.Sprintf("%s%020d", s.ChunkPrefix(), offset)
+}
+
+// CreateUploadRequest represents the request to start a resumable upload
+type CreateUploadRequest struct {
+	Filename string `json:"filename"`
+	Size     int64  `json:"size"`
+	Checksum string `json:"checksum"`
+}
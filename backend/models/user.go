@@ -9,14 +9,23 @@ import (
 
 // User represents a user in the system
 type User struct {
-	ID        uint           `gorm:"primaryKey" json:"id"`
-	Name      string         `gorm:"not null" json:"name"`
-	Email     string         `gorm:"uniqueIndex;not null" json:"email"`
-	Password  string         `gorm:"not null" json:"-"` // "-" means don't include in JSON
-	Notes     []Note         `gorm:"foreignKey:UserID" json:"notes,omitempty"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	ID                    uint           `gorm:"primaryKey" json:"id"`
+	Name                  string         `gorm:"not null" json:"name"`
+	Email                 string         `gorm:"uniqueIndex;not null" json:"email"`
+	EmailVerifiedAt       *time.Time     `json:"email_verified_at"`
+	Password              string         `gorm:"not null" json:"-"` // "-" means don't include in JSON
+	CalendarTokenHash     *string        `gorm:"uniqueIndex" json:"-"`
+	PreserveImageMetadata bool           `gorm:"not null;default:false" json:"preserve_image_metadata"`
+	TokenGeneration       int            `gorm:"not null;default:0" json:"-"` // bumped to invalidate all issued tokens
+	TOTPSecret            *string        `json:"-"`                           // set during enrollment, active once TwoFactorEnabled
+	TOTPLastStep          int64          `gorm:"not null;default:0" json:"-"` // time step of the last accepted code, to prevent replay
+	TwoFactorEnabled      bool           `gorm:"not null;default:false" json:"two_factor_enabled"`
+	TwoFactorFailures     int            `gorm:"not null;default:0" json:"-"` // wrong second factor codes in a row
+	TwoFactorLockedUntil  *time.Time     `json:"-"`                           // no codes are checked until then
+	Notes                 []Note         `gorm:"foreignKey:UserID" json:"notes,omitempty"`
+	CreatedAt             time.Time      `json:"created_at"`
+	UpdatedAt             time.Time      `json:"updated_at"`
+	DeletedAt             gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 // HashPassword hashes the user's password
@@ -47,8 +56,54 @@ type LoginRequest struct {
 	Password string `json:"password" validate:"required"`
 }
 
+// VerifyEmailRequest represents the email verification request payload
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// ChangePasswordRequest represents the change password request payload
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,min=6"`
+}
+
+// ChangeEmailRequest represents the change email request payload
+type ChangeEmailRequest struct {
+	NewEmail string `json:"new_email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
+}
+
+// EnrollTwoFactorRequest represents the start 2FA enrollment request payload
+type EnrollTwoFactorRequest struct {
+	Password string `json:"password" validate:"required"`
+}
+
+// TwoFactorCodeRequest represents a request carrying a TOTP or recovery code
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// DisableTwoFactorRequest represents the disable 2FA request payload
+type DisableTwoFactorRequest struct {
+	Password string `json:"password" validate:"required"`
+	Code     string `json:"code" validate:"required"`
+}
+
+// VerifyTwoFactorRequest represents the request completing a 2FA login
+type VerifyTwoFactorRequest struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	Code           string `json:"code" validate:"required"`
+}
+
 // LoginResponse represents the login response
 type LoginResponse struct {
-	Token string `json:"token"`
-	User  User   `json:"user"`
-}
\ No newline at end of file
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"` // access token lifetime in seconds
+	User         User   `json:"user"`
+}
+
+// UpdateSettingsRequest represents the update settings request payload
+type UpdateSettingsRequest struct {
+	PreserveImageMetadata *bool `json:"preserve_image_metadata"`
+}
@@ -0,0 +1,178 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+)
+
+// minKeyRefresh limits how often an unknown key ID triggers a JWKS refetch,
+// so tokens with made-up key IDs can't be used to hammer the provider
+const minKeyRefresh = time.Minute
+
+// jsonWebKey is a key of a JSON Web Key Set (RFC 7517)
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// signingKey is a parsed public key of the provider
+type signingKey struct {
+	alg string // the algorithm the key is restricted to, if any
+	key crypto.PublicKey
+}
+
+// keySet caches the provider's signing keys, refetching them when a token
+// names a key that isn't known yet (the provider rotated its keys)
+type keySet struct {
+	uri     string
+	getJSON func(ctx context.Context, url string, v interface{}) error
+
+	mu        sync.Mutex
+	keys      map[string]signingKey
+	fetchedAt time.Time
+}
+
+func newKeySet(uri string, getJSON func(ctx context.Context, url string, v interface{}) error) *keySet {
+	return &keySet{uri: uri, getJSON: getJSON}
+}
+
+// key returns the public key with the given key ID for verifying a token
+// signed with alg
+func (ks *keySet) key(ctx context.Context, kid, alg string) (crypto.PublicKey, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	k, ok := ks.lookup(kid)
+	if !ok && time.Since(ks.fetchedAt) >= minKeyRefresh {
+		if err := ks.refresh(ctx); err != nil {
+			return nil, err
+		}
+		k, ok = ks.lookup(kid)
+	}
+	if !ok {
+		return nil, fmt.Errorf("oidc: unknown signing key %q", kid)
+	}
+	if k.alg != "" && k.alg != alg {
+		return nil, fmt.Errorf("oidc: signing key %q is not for %s", kid, alg)
+	}
+	return k.key, nil
+}
+
+// lookup finds a cached key. A token without a key ID is only accepted when
+// the provider has a single key.
+func (ks *keySet) lookup(kid string) (signingKey, bool) {
+	if kid == "" {
+		if len(ks.keys) != 1 {
+			return signingKey{}, false
+		}
+		for _, k := range ks.keys {
+			return k, true
+		}
+	}
+	k, ok := ks.keys[kid]
+	return k, ok
+}
+
+// refresh fetches the key set, skipping keys that aren't usable for signatures
+func (ks *keySet) refresh(ctx context.Context) error {
+	ks.fetchedAt = time.Now()
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := ks.getJSON(ctx, ks.uri, &set); err != nil {
+		return fmt.Errorf("oidc: fetching signing keys failed: %w", err)
+	}
+
+	keys := make(map[string]signingKey)
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = signingKey{alg: jwk.Alg, key: key}
+	}
+	ks.keys = keys
+	return nil
+}
+
+// publicKey parses an RSA or EC (P-256, P-384) public key
+func (k *jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("oidc: invalid RSA exponent")
+		}
+		key := &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+		if key.N.BitLen() < 2048 || key.E < 3 || key.E%2 == 0 {
+			return nil, errors.New("oidc: weak RSA key")
+		}
+		return key, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		var validate ecdh.Curve
+		switch k.Crv {
+		case "P-256":
+			curve, validate = elliptic.P256(), ecdh.P256()
+		case "P-384":
+			curve, validate = elliptic.P384(), ecdh.P384()
+		default:
+			return nil, fmt.Errorf("oidc: unsupported curve %q", k.Crv)
+		}
+
+		size := (curve.Params().BitSize + 7) / 8
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != size {
+			return nil, errors.New("oidc: invalid EC key")
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil || len(y) != size {
+			return nil, errors.New("oidc: invalid EC key")
+		}
+
+		// Reject points that aren't on the curve
+		point := append(append([]byte{4}, x...), y...)
+		if _, err := validate.NewPublicKey(point); err != nil {
+			return nil, errors.New("oidc: invalid EC key")
+		}
+		return &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, nil
+	}
+
+	return nil, fmt.Errorf("oidc: unsupported key type %q", k.Kty)
+}
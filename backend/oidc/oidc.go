@@ -0,0 +1,238 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"notes-app/config"
+	"notes-app/utils"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OpenID Connect relying party for single sign-on with the authorization
+// code flow and PKCE. Provider metadata and signing keys are fetched lazily,
+// so the app starts even while an identity provider is unreachable.
+
+// ErrUnknownProvider is returned by Get for a provider that isn't configured
+var ErrUnknownProvider = errors.New("oidc: unknown provider")
+
+// Providers are the configured identity providers, in configuration order
+var Providers []*Provider
+
+// Setup initializes Providers from the configuration. Providers without an
+// issuer URL or client ID are logged and left out.
+func Setup(cfg *config.Config) error {
+	Providers = nil
+	for _, pc := range cfg.OIDCProviders {
+		if _, err := Get(pc.ID); err == nil {
+			return fmt.Errorf("oidc: provider %q configured twice", pc.ID)
+		}
+
+		env := "OIDC_" + strings.ToUpper(strings.ReplaceAll(pc.ID, "-", "_"))
+		issuer, err := url.Parse(pc.Issuer)
+		if pc.Issuer == "" || err != nil || (issuer.Scheme != "https" && issuer.Scheme != "http") || issuer.Host == "" {
+			utils.LogWarning(fmt.Sprintf("OIDC provider %s needs an issuer URL in %s_ISSUER, skipping it", pc.ID, env))
+			continue
+		}
+		if pc.ClientID == "" {
+			utils.LogWarning(fmt.Sprintf("OIDC provider %s needs %s_CLIENT_ID, skipping it", pc.ID, env))
+			continue
+		}
+
+		Providers = append(Providers, NewProvider(pc))
+	}
+	return nil
+}
+
+// Get returns the configured provider with the given ID
+func Get(id string) (*Provider, error) {
+	for _, p := range Providers {
+		if p.ID == id {
+			return p, nil
+		}
+	}
+	return nil, ErrUnknownProvider
+}
+
+// metadata is the part of the provider's discovery document that is used
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider is an OpenID Connect identity provider
+type Provider struct {
+	ID   string
+	Name string
+
+	cfg    config.OIDCProvider
+	client *http.Client
+
+	mu       sync.Mutex
+	metadata *metadata
+	keys     *keySet
+}
+
+// NewProvider creates a Provider from its configuration
+func NewProvider(cfg config.OIDCProvider) *Provider {
+	return &Provider{
+		ID:     cfg.ID,
+		Name:   cfg.Name,
+		cfg:    cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// AuthCodeURL returns the URL to send the user to for logging in. state and
+// nonce bind the response to this login attempt, codeVerifier is the PKCE
+// secret later presented to Exchange.
+func (p *Provider) AuthCodeURL(ctx context.Context, redirectURI, state, nonce, codeVerifier string) (string, error) {
+	md, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientID},
+		"redirect_uri":          {redirectURI},
+		"scope":                 {strings.Join(p.cfg.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {CodeChallenge(codeVerifier)},
+		"code_challenge_method": {"S256"},
+	}
+
+	sep := "?"
+	if strings.Contains(md.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return md.AuthorizationEndpoint + sep + params.Encode(), nil
+}
+
+// tokenResponse is the token endpoint's response
+type tokenResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Exchange redeems an authorization code at the token endpoint and returns
+// the verified ID token
+func (p *Provider) Exchange(ctx context.Context, redirectURI, code, codeVerifier, nonce string) (*IDToken, error) {
+	md, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {codeVerifier},
+		"client_id":     {p.cfg.ClientID},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, md.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var tr tokenResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&tr); err != nil {
+		return nil, fmt.Errorf("oidc: invalid token response (status %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK || tr.Error != "" {
+		return nil, fmt.Errorf("oidc: token request rejected (status %d): %s %s", resp.StatusCode, tr.Error, tr.ErrorDescription)
+	}
+	if tr.IDToken == "" {
+		return nil, errors.New("oidc: token response has no id_token")
+	}
+
+	return p.VerifyIDToken(ctx, tr.IDToken, nonce)
+}
+
+// discover fetches and caches the provider's discovery document
+func (p *Provider) discover(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	var md metadata
+	wellKnown := strings.TrimSuffix(p.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := p.getJSON(ctx, wellKnown, &md); err != nil {
+		return nil, fmt.Errorf("oidc: discovery for %s failed: %w", p.ID, err)
+	}
+
+	// The issuer must match exactly, otherwise ID tokens could be minted by
+	// whoever serves the document
+	if md.Issuer != p.cfg.Issuer {
+		return nil, fmt.Errorf("oidc: discovery for %s returned issuer %q, expected %q", p.ID, md.Issuer, p.cfg.Issuer)
+	}
+	if md.AuthorizationEndpoint == "" || md.TokenEndpoint == "" || md.JWKSURI == "" {
+		return nil, fmt.Errorf("oidc: discovery document for %s is incomplete", p.ID)
+	}
+
+	p.metadata = &md
+	p.keys = newKeySet(md.JWKSURI, p.getJSON)
+	return p.metadata, nil
+}
+
+// getJSON fetches a JSON document
+func (p *Provider) getJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+// GenerateCodeVerifier returns a random PKCE code verifier
+func GenerateCodeVerifier() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// CodeChallenge derives the S256 PKCE code challenge from a code verifier
+func CodeChallenge(codeVerifier string) string {
+	sum := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,529 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"notes-app/config"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testClientID     = "notes-client"
+	testClientSecret = "notes-secret"
+	testRedirectURI  = "https://notes.example.com/sso/callback"
+)
+
+// mockIdP is an in-process OpenID Connect provider serving discovery, JWKS
+// and token endpoints
+type mockIdP struct {
+	*httptest.Server
+	t *testing.T
+
+	mu          sync.Mutex
+	keys        map[string]interface{} // kid -> private key, published in the JWKS
+	jwksFetches int
+	codes       map[string]mockCode // authorization codes the token endpoint redeems
+}
+
+type mockCode struct {
+	codeChallenge string
+	idToken       string
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	idp := &mockIdP{t: t, keys: map[string]interface{}{}, codes: map[string]mockCode{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.URL,
+			"authorization_endpoint": idp.URL + "/authorize",
+			"token_endpoint":         idp.URL + "/token",
+			"jwks_uri":               idp.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", idp.serveJWKS)
+	mux.HandleFunc("/token", idp.serveToken)
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+
+	idp.addKey("rsa-1", mustRSAKey(t))
+	return idp
+}
+
+func mustRSAKey(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func mustECKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func (idp *mockIdP) addKey(kid string, key interface{}) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.keys[kid] = key
+}
+
+func (idp *mockIdP) fetches() int {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	return idp.jwksFetches
+}
+
+func (idp *mockIdP) removeKey(kid string) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	delete(idp.keys, kid)
+}
+
+func b64(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+func (idp *mockIdP) serveJWKS(w http.ResponseWriter, r *http.Request) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.jwksFetches++
+
+	var keys []jsonWebKey
+	for kid, key := range idp.keys {
+		switch key := key.(type) {
+		case *rsa.PrivateKey:
+			keys = append(keys, jsonWebKey{Kty: "RSA", Kid: kid, Use: "sig", Alg: "RS256",
+				N: b64(key.N.Bytes()), E: b64(big.NewInt(int64(key.E)).Bytes())})
+		case *ecdsa.PrivateKey:
+			keys = append(keys, jsonWebKey{Kty: "EC", Kid: kid, Use: "sig", Alg: "ES256", Crv: "P-256",
+				X: b64(key.X.FillBytes(make([]byte, 32))), Y: b64(key.Y.FillBytes(make([]byte, 32)))})
+		}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+}
+
+func (idp *mockIdP) serveToken(w http.ResponseWriter, r *http.Request) {
+	reject := func(status int, code string) {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"error": code})
+	}
+
+	if r.Method != http.MethodPost || r.ParseForm() != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		reject(http.StatusBadRequest, "invalid_request")
+		return
+	}
+	clientID, secret, ok := r.BasicAuth()
+	if !ok || clientID != testClientID || secret != testClientSecret {
+		reject(http.StatusUnauthorized, "invalid_client")
+		return
+	}
+
+	idp.mu.Lock()
+	code, ok := idp.codes[r.PostForm.Get("code")]
+	delete(idp.codes, r.PostForm.Get("code"))
+	idp.mu.Unlock()
+	if !ok || r.PostForm.Get("redirect_uri") != testRedirectURI ||
+		CodeChallenge(r.PostForm.Get("code_verifier")) != code.codeChallenge {
+		reject(http.StatusBadRequest, "invalid_grant")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"id_token": code.idToken, "token_type": "Bearer"})
+}
+
+// issueCode makes the token endpoint redeem code for idToken, if the client
+// presents the verifier for codeChallenge
+func (idp *mockIdP) issueCode(code, codeChallenge, idToken string) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.codes[code] = mockCode{codeChallenge: codeChallenge, idToken: idToken}
+}
+
+// claims returns valid ID token claims for nonce
+func (idp *mockIdP) claims(nonce string) jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":            idp.URL,
+		"sub":            "user-123",
+		"aud":            testClientID,
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"iat":            now.Unix(),
+		"nonce":          nonce,
+		"email":          "jane@example.com",
+		"email_verified": true,
+		"name":           "Jane Doe",
+	}
+}
+
+// sign signs claims with the key published under kid
+func (idp *mockIdP) sign(kid string, claims jwt.MapClaims) string {
+	idp.mu.Lock()
+	key := idp.keys[kid]
+	idp.mu.Unlock()
+	return signWith(idp.t, kid, key, claims)
+}
+
+func signWith(t *testing.T, kid string, key interface{}, claims jwt.MapClaims) string {
+	t.Helper()
+	var method jwt.SigningMethod = jwt.SigningMethodRS256
+	if _, ok := key.(*ecdsa.PrivateKey); ok {
+		method = jwt.SigningMethodES256
+	}
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func (idp *mockIdP) provider() *Provider {
+	return NewProvider(config.OIDCProvider{
+		ID:           "mock",
+		Name:         "Mock",
+		Issuer:       idp.URL,
+		ClientID:     testClientID,
+		ClientSecret: testClientSecret,
+		Scopes:       []string{"openid", "email"},
+	})
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	idp := newMockIdP(t)
+	p := idp.provider()
+	ctx := context.Background()
+
+	codeVerifier, err := GenerateCodeVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	authURL, err := p.AuthCodeURL(ctx, testRedirectURI, "the-state", "the-nonce", codeVerifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := url.Parse(authURL)
+	if err != nil || !strings.HasPrefix(authURL, idp.URL+"/authorize?") {
+		t.Fatalf("unexpected authorization URL %q", authURL)
+	}
+	q := u.Query()
+	for param, want := range map[string]string{
+		"response_type":         "code",
+		"client_id":             testClientID,
+		"redirect_uri":          testRedirectURI,
+		"scope":                 "openid email",
+		"state":                 "the-state",
+		"nonce":                 "the-nonce",
+		"code_challenge_method": "S256",
+	} {
+		if got := q.Get(param); got != want {
+			t.Errorf("%s = %q, want %q", param, got, want)
+		}
+	}
+
+	idp.issueCode("the-code", q.Get("code_challenge"), idp.sign("rsa-1", idp.claims("the-nonce")))
+	idToken, err := p.Exchange(ctx, testRedirectURI, "the-code", codeVerifier, "the-nonce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := IDToken{Subject: "user-123", Email: "jane@example.com", EmailVerified: true, Name: "Jane Doe"}
+	if *idToken != want {
+		t.Fatalf("got %+v, want %+v", *idToken, want)
+	}
+
+	// Codes are single-use
+	if _, err := p.Exchange(ctx, testRedirectURI, "the-code", codeVerifier, "the-nonce"); err == nil {
+		t.Fatal("authorization code redeemed twice")
+	}
+}
+
+func TestExchangeRejected(t *testing.T) {
+	idp := newMockIdP(t)
+	ctx := context.Background()
+	codeVerifier, _ := GenerateCodeVerifier()
+
+	t.Run("wrong code verifier", func(t *testing.T) {
+		otherVerifier, _ := GenerateCodeVerifier()
+		idp.issueCode("code-1", CodeChallenge(otherVerifier), idp.sign("rsa-1", idp.claims("n")))
+		if _, err := idp.provider().Exchange(ctx, testRedirectURI, "code-1", codeVerifier, "n"); err == nil {
+			t.Fatal("exchange accepted")
+		}
+	})
+
+	t.Run("wrong client secret", func(t *testing.T) {
+		p := idp.provider()
+		p.cfg.ClientSecret = "wrong"
+		idp.issueCode("code-2", CodeChallenge(codeVerifier), idp.sign("rsa-1", idp.claims("n")))
+		if _, err := p.Exchange(ctx, testRedirectURI, "code-2", codeVerifier, "n"); err == nil {
+			t.Fatal("exchange accepted")
+		}
+	})
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	idp := newMockIdP(t)
+	idp.addKey("ec-1", mustECKey(t))
+	ctx := context.Background()
+
+	for _, kid := range []string{"rsa-1", "ec-1"} {
+		if _, err := idp.provider().VerifyIDToken(ctx, idp.sign(kid, idp.claims("n")), "n"); err != nil {
+			t.Fatalf("%s: %v", kid, err)
+		}
+	}
+}
+
+func TestVerifyIDTokenRejected(t *testing.T) {
+	idp := newMockIdP(t)
+	ctx := context.Background()
+	attackerKey := mustRSAKey(t)
+
+	tests := []struct {
+		name  string
+		token func() string
+	}{
+		{"bad signature", func() string {
+			return signWith(t, "rsa-1", attackerKey, idp.claims("n"))
+		}},
+		{"tampered payload", func() string {
+			parts := strings.Split(idp.sign("rsa-1", idp.claims("n")), ".")
+			claims := idp.claims("n")
+			claims["sub"] = "admin"
+			payload, _ := json.Marshal(claims)
+			parts[1] = b64(payload)
+			return strings.Join(parts, ".")
+		}},
+		{"wrong issuer", func() string {
+			claims := idp.claims("n")
+			claims["iss"] = "https://evil.example.com"
+			return idp.sign("rsa-1", claims)
+		}},
+		{"wrong audience", func() string {
+			claims := idp.claims("n")
+			claims["aud"] = "other-client"
+			return idp.sign("rsa-1", claims)
+		}},
+		{"several audiences without azp", func() string {
+			claims := idp.claims("n")
+			claims["aud"] = []string{testClientID, "other-client"}
+			return idp.sign("rsa-1", claims)
+		}},
+		{"azp of another client", func() string {
+			claims := idp.claims("n")
+			claims["azp"] = "other-client"
+			return idp.sign("rsa-1", claims)
+		}},
+		{"expired", func() string {
+			claims := idp.claims("n")
+			claims["exp"] = time.Now().Add(-5 * time.Minute).Unix()
+			return idp.sign("rsa-1", claims)
+		}},
+		{"no expiry", func() string {
+			claims := idp.claims("n")
+			delete(claims, "exp")
+			return idp.sign("rsa-1", claims)
+		}},
+		{"issued in the future", func() string {
+			claims := idp.claims("n")
+			claims["iat"] = time.Now().Add(time.Hour).Unix()
+			return idp.sign("rsa-1", claims)
+		}},
+		{"nonce mismatch", func() string {
+			return idp.sign("rsa-1", idp.claims("other-nonce"))
+		}},
+		{"no nonce", func() string {
+			claims := idp.claims("n")
+			delete(claims, "nonce")
+			return idp.sign("rsa-1", claims)
+		}},
+		{"no subject", func() string {
+			claims := idp.claims("n")
+			delete(claims, "sub")
+			return idp.sign("rsa-1", claims)
+		}},
+		{"unknown key", func() string {
+			return signWith(t, "rsa-unknown", attackerKey, idp.claims("n"))
+		}},
+		{"algorithm none", func() string {
+			token := jwt.NewWithClaims(jwt.SigningMethodNone, idp.claims("n"))
+			token.Header["kid"] = "rsa-1"
+			signed, _ := token.SignedString(jwt.UnsafeAllowNoneSignatureType)
+			return signed
+		}},
+		{"HMAC with the public key", func() string {
+			token := jwt.NewWithClaims(jwt.SigningMethodHS256, idp.claims("n"))
+			token.Header["kid"] = "rsa-1"
+			idp.mu.Lock()
+			pub := idp.keys["rsa-1"].(*rsa.PrivateKey).N.Bytes()
+			idp.mu.Unlock()
+			signed, _ := token.SignedString(pub)
+			return signed
+		}},
+		{"key used for another algorithm", func() string {
+			token := jwt.NewWithClaims(jwt.SigningMethodRS384, idp.claims("n"))
+			token.Header["kid"] = "rsa-1"
+			idp.mu.Lock()
+			key := idp.keys["rsa-1"]
+			idp.mu.Unlock()
+			signed, _ := token.SignedString(key)
+			return signed
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := idp.provider().VerifyIDToken(ctx, tt.token(), "n"); err == nil {
+				t.Fatal("ID token accepted")
+			}
+		})
+	}
+}
+
+func TestVerifyIDTokenWithoutKeyID(t *testing.T) {
+	idp := newMockIdP(t)
+	ctx := context.Background()
+
+	idp.mu.Lock()
+	key := idp.keys["rsa-1"]
+	idp.mu.Unlock()
+	token := signWith(t, "", key, idp.claims("n"))
+
+	// With a single key there's no doubt which one signed the token
+	if _, err := idp.provider().VerifyIDToken(ctx, token, "n"); err != nil {
+		t.Fatal(err)
+	}
+
+	idp.addKey("rsa-2", mustRSAKey(t))
+	if _, err := idp.provider().VerifyIDToken(ctx, token, "n"); err == nil {
+		t.Fatal("token without key ID accepted with several keys")
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	idp := newMockIdP(t)
+	p := idp.provider()
+	ctx := context.Background()
+
+	if _, err := p.VerifyIDToken(ctx, idp.sign("rsa-1", idp.claims("n")), "n"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The provider rotates to a new key
+	idp.addKey("rsa-2", mustRSAKey(t))
+	idp.removeKey("rsa-1")
+	rotated := idp.sign("rsa-2", idp.claims("n"))
+
+	// Unknown key IDs don't refetch the keys more than once a minute
+	if _, err := p.VerifyIDToken(ctx, rotated, "n"); err == nil {
+		t.Fatal("token verified without refetching the keys")
+	}
+	if n := idp.fetches(); n != 1 {
+		t.Fatalf("keys fetched %d times, want 1", n)
+	}
+
+	p.keys.mu.Lock()
+	p.keys.fetchedAt = time.Now().Add(-minKeyRefresh)
+	p.keys.mu.Unlock()
+
+	if _, err := p.VerifyIDToken(ctx, rotated, "n"); err != nil {
+		t.Fatalf("token signed with the rotated key: %v", err)
+	}
+	if n := idp.fetches(); n != 2 {
+		t.Fatalf("keys fetched %d times, want 2", n)
+	}
+
+	// The retired key is no longer trusted
+	p.keys.mu.Lock()
+	_, ok := p.keys.keys["rsa-1"]
+	p.keys.mu.Unlock()
+	if ok {
+		t.Fatal("retired key still trusted")
+	}
+}
+
+func TestEmailVerifiedClaim(t *testing.T) {
+	idp := newMockIdP(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		value interface{}
+		want  bool
+	}{
+		{true, true},
+		{"true", true},
+		{false, false},
+		{"false", false},
+		{1, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		claims := idp.claims("n")
+		if tt.value == nil {
+			delete(claims, "email_verified")
+		} else {
+			claims["email_verified"] = tt.value
+		}
+		idToken, err := idp.provider().VerifyIDToken(ctx, idp.sign("rsa-1", claims), "n")
+		if err != nil {
+			t.Fatalf("email_verified=%v: %v", tt.value, err)
+		}
+		if idToken.EmailVerified != tt.want {
+			t.Errorf("email_verified=%v: got %v, want %v", tt.value, idToken.EmailVerified, tt.want)
+		}
+	}
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	idp := newMockIdP(t)
+	p := idp.provider()
+	p.cfg.Issuer = idp.URL + "/"
+
+	if _, err := p.VerifyIDToken(context.Background(), idp.sign("rsa-1", idp.claims("n")), "n"); err == nil {
+		t.Fatal("provider with a mismatched issuer accepted")
+	}
+}
+
+func TestSetup(t *testing.T) {
+	defer func() { Providers = nil }()
+
+	cfg := &config.Config{OIDCProviders: []config.OIDCProvider{
+		{ID: "good", Issuer: "https://idp.example.com", ClientID: "client"},
+		{ID: "no-issuer", ClientID: "client"},
+		{ID: "bad-issuer", Issuer: "idp.example.com", ClientID: "client"},
+		{ID: "no-client", Issuer: "https://idp.example.com"},
+	}}
+	if err := Setup(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if len(Providers) != 1 || Providers[0].ID != "good" {
+		t.Fatalf("got %d providers, want only the valid one", len(Providers))
+	}
+	if _, err := Get("no-client"); err != ErrUnknownProvider {
+		t.Fatalf("incomplete provider configured: %v", err)
+	}
+
+	cfg.OIDCProviders = append(cfg.OIDCProviders, cfg.OIDCProviders[0])
+	if err := Setup(cfg); err == nil {
+		t.Fatal("duplicate provider accepted")
+	}
+}
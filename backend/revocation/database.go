@@ -0,0 +1,33 @@
+package revocation
+
+import (
+	"notes-app/database"
+	"notes-app/models"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// DatabaseStore keeps revoked tokens in the revoked_tokens table, so
+// revocations survive restarts and are shared by all instances. Expired rows
+// are removed by the token cleanup job.
+type DatabaseStore struct{}
+
+// NewDatabaseStore creates a DatabaseStore
+func NewDatabaseStore() *DatabaseStore {
+	return &DatabaseStore{}
+}
+
+// Revoke implements Store
+func (s *DatabaseStore) Revoke(jti string, expiresAt time.Time) error {
+	return database.DB.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.RevokedToken{JTI: jti, ExpiresAt: expiresAt}).Error
+}
+
+// IsRevoked implements Store
+func (s *DatabaseStore) IsRevoked(jti string) (bool, error) {
+	var count int64
+	err := database.DB.Model(&models.RevokedToken{}).
+		Where("jti = ? AND expires_at > ?", jti, time.Now()).Count(&count).Error
+	return count > 0, err
+}
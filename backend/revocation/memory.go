@@ -0,0 +1,57 @@
+package revocation
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps revoked tokens in process memory. Revocations are lost
+// on restart and not shared between instances, so it suits single-instance
+// deployments only.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	revoked map[string]time.Time
+}
+
+// NewMemoryStore creates a MemoryStore that evicts expired entries every interval
+func NewMemoryStore(interval time.Duration) *MemoryStore {
+	s := &MemoryStore{revoked: make(map[string]time.Time)}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.evictExpired()
+		}
+	}()
+
+	return s
+}
+
+// Revoke implements Store
+func (s *MemoryStore) Revoke(jti string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revoked[jti] = expiresAt
+	return nil
+}
+
+// IsRevoked implements Store
+func (s *MemoryStore) IsRevoked(jti string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	expiresAt, ok := s.revoked[jti]
+	return ok && time.Now().Before(expiresAt), nil
+}
+
+// evictExpired drops entries whose token has expired anyway
+func (s *MemoryStore) evictExpired() {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for jti, expiresAt := range s.revoked {
+		if !now.Before(expiresAt) {
+			delete(s.revoked, jti)
+		}
+	}
+}
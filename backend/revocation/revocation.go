@@ -0,0 +1,32 @@
+package revocation
+
+import (
+	"fmt"
+	"notes-app/config"
+	"time"
+)
+
+// Store keeps track of access tokens revoked before their expiry. Entries
+// only need to outlive the token they revoke.
+type Store interface {
+	// Revoke marks the token with the given jti as revoked until expiresAt
+	Revoke(jti string, expiresAt time.Time) error
+	// IsRevoked reports whether the token with the given jti has been revoked
+	IsRevoked(jti string) (bool, error)
+}
+
+// Tokens is the revocation store selected in the configuration
+var Tokens Store
+
+// Setup initializes Tokens from the configuration
+func Setup(cfg *config.Config) error {
+	switch cfg.RevocationStore {
+	case "memory":
+		Tokens = NewMemoryStore(time.Minute)
+	case "database":
+		Tokens = NewDatabaseStore()
+	default:
+		return fmt.Errorf("revocation: unknown store %q", cfg.RevocationStore)
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package routes
 import (
 	"notes-app/handlers"
 	"notes-app/middleware"
+	"notes-app/models"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -16,13 +17,89 @@ func SetupRoutes(app *fiber.App) {
 	auth := api.Group("/auth")
 	auth.Post("/register", handlers.Register)
 	auth.Post("/login", handlers.Login)
+	auth.Post("/refresh", handlers.Refresh)
+	auth.Post("/forgot-password", handlers.ForgotPassword)
+	auth.Post("/reset-password", handlers.ResetPassword)
+	auth.Post("/2fa/verify", handlers.VerifyTwoFactor)
+	auth.Post("/passkeys/login/begin", handlers.BeginPasskeyLogin)
+	auth.Post("/passkeys/login/finish", handlers.FinishPasskeyLogin)
+	auth.Get("/oidc/providers", handlers.GetOIDCProviders)
+	auth.Post("/oidc/:provider/begin", handlers.BeginOIDCLogin)
+	auth.Post("/oidc/callback", handlers.FinishOIDCLogin)
+	auth.Post("/verify-email", handlers.VerifyEmail)
+	auth.Post("/confirm-email", handlers.ConfirmEmailChange)
+	auth.Post("/resend-verification", middleware.AuthMiddleware, handlers.ResendVerification)
+	auth.Post("/logout", middleware.AuthMiddleware, handlers.Logout)
+	auth.Post("/logout-all", middleware.AuthMiddleware, handlers.LogoutAll)
+	auth.Get("/sessions", middleware.AuthMiddleware, handlers.GetSessions)
+	auth.Delete("/sessions/:id", middleware.AuthMiddleware, handlers.DeleteSession)
 
-	// Notes routes (authentication required)
-	notes := api.Group("/notes", middleware.AuthMiddleware)
-	notes.Get("/", handlers.GetNotes)
-	notes.Post("/", handlers.CreateNote)
-	notes.Get("/:id", handlers.GetNote)
-	notes.Put("/:id", handlers.UpdateNote)
-	notes.Delete("/:id", handlers.DeleteNote)
-	notes.Post("/:id/upload", handlers.UploadImage)
+	// Notes routes (authentication required: each route authenticates with
+	// RequireScope, which also accepts personal access tokens with the scope)
+	notes := api.Group("/notes")
+	notes.Get("/", middleware.RequireScope(models.ScopeNotesRead), handlers.GetNotes)
+	notes.Post("/", middleware.RequireScope(models.ScopeNotesWrite), handlers.CreateNote)
+	notes.Post("/from-template/:templateID", middleware.RequireScope(models.ScopeNotesWrite), handlers.CreateNoteFromTemplate)
+	notes.Get("/due", middleware.RequireScope(models.ScopeNotesRead), handlers.GetDueNotes)
+	notes.Get("/:id", middleware.RequireScope(models.ScopeNotesRead), handlers.GetNote)
+	notes.Put("/:id", middleware.RequireScope(models.ScopeNotesWrite), handlers.UpdateNote)
+	notes.Delete("/:id", middleware.RequireScope(models.ScopeNotesWrite), handlers.DeleteNote)
+	notes.Post("/:id/upload", middleware.RequireScope(models.ScopeAttachmentsWrite), handlers.UploadImage)
+	notes.Get("/:id/links", middleware.RequireScope(models.ScopeNotesRead), handlers.GetNoteLinks)
+	notes.Get("/:id/backlinks", middleware.RequireScope(models.ScopeNotesRead), handlers.GetNoteBacklinks)
+	notes.Get("/:id/attachments", middleware.RequireScope(models.ScopeNotesRead), handlers.GetAttachments)
+	notes.Post("/:id/attachments", middleware.RequireScope(models.ScopeAttachmentsWrite), handlers.UploadAttachment)
+	notes.Get("/:id/attachments/:attachmentID", middleware.RequireScope(models.ScopeNotesRead), handlers.DownloadAttachment)
+	notes.Delete("/:id/attachments/:attachmentID", middleware.RequireScope(models.ScopeAttachmentsWrite), handlers.DeleteAttachment)
+	notes.Post("/:id/uploads", middleware.RequireScope(models.ScopeAttachmentsWrite), handlers.CreateUpload)
+	notes.Get("/:id/uploads/:uploadID", middleware.RequireScope(models.ScopeAttachmentsWrite), handlers.GetUpload)
+	notes.Patch("/:id/uploads/:uploadID", middleware.RequireScope(models.ScopeAttachmentsWrite), handlers.UploadChunk)
+	notes.Delete("/:id/uploads/:uploadID", middleware.RequireScope(models.ScopeAttachmentsWrite), handlers.DeleteUpload)
+	notes.Post("/:id/reminders", middleware.RequireScope(models.ScopeNotesWrite), handlers.CreateReminder)
+	notes.Delete("/:id/reminders/:reminderID", middleware.RequireScope(models.ScopeNotesWrite), handlers.DeleteReminder)
+	notes.Post("/:id/reminders/:reminderID/snooze", middleware.RequireScope(models.ScopeNotesWrite), handlers.SnoozeReminder)
+
+	// Notification routes (authentication required)
+	notifications := api.Group("/notifications", middleware.AuthMiddleware)
+	notifications.Get("/", handlers.GetNotifications)
+	notifications.Put("/:id/read", handlers.MarkNotificationRead)
+
+	// Template routes (authentication required: each route authenticates with
+	// RequireScope, which also accepts personal access tokens with the scope)
+	templates := api.Group("/templates")
+	templates.Get("/", middleware.RequireScope(models.ScopeNotesRead), handlers.GetTemplates)
+	templates.Post("/", middleware.RequireScope(models.ScopeNotesWrite), handlers.CreateTemplate)
+	templates.Get("/:id", middleware.RequireScope(models.ScopeNotesRead), handlers.GetTemplate)
+	templates.Put("/:id", middleware.RequireScope(models.ScopeNotesWrite), handlers.UpdateTemplate)
+	templates.Delete("/:id", middleware.RequireScope(models.ScopeNotesWrite), handlers.DeleteTemplate)
+
+	// Current user routes (authentication required)
+	me := api.Group("/me", middleware.AuthMiddleware)
+	me.Get("/settings", handlers.GetSettings)
+	me.Put("/settings", handlers.UpdateSettings)
+	me.Get("/usage", handlers.GetUsage)
+	me.Put("/password", handlers.ChangePassword)
+	me.Put("/email", handlers.ChangeEmail)
+	me.Post("/2fa/enroll", handlers.EnrollTwoFactor)
+	me.Post("/2fa/confirm", handlers.ConfirmTwoFactor)
+	me.Post("/2fa/recovery-codes", handlers.RegenerateRecoveryCodes)
+	me.Post("/2fa/disable", handlers.DisableTwoFactor)
+	me.Get("/passkeys", handlers.GetPasskeys)
+	me.Post("/passkeys/register/begin", handlers.BeginPasskeyRegistration)
+	me.Post("/passkeys/register/finish", handlers.FinishPasskeyRegistration)
+	me.Delete("/passkeys/:id", handlers.DeletePasskey)
+	me.Get("/tokens", handlers.GetPersonalAccessTokens)
+	me.Post("/tokens", middleware.RequireVerifiedEmail, handlers.CreatePersonalAccessToken)
+	me.Delete("/tokens/:id", handlers.DeletePersonalAccessToken)
+	me.Post("/calendar-token", middleware.RequireVerifiedEmail, handlers.RegenerateCalendarToken)
+	me.Delete("/calendar-token", handlers.DeleteCalendarToken)
+
+	// Uploaded files (authenticated by the signed URL)
+	api.Get("/files/*", handlers.ServeFile)
+
+	// Calendar feed (authenticated by the secret token in the URL)
+	api.Get("/calendar/:token.ics", handlers.GetCalendarFeed)
+
+	// Graph routes (authentication required)
+	api.Get("/graph", middleware.RequireScope(models.ScopeNotesRead), handlers.GetGraph)
 }
\ No newline at end of file
@@ -0,0 +1,94 @@
+package scheduler
+
+import (
+	"fmt"
+	"notes-app/database"
+	"notes-app/models"
+	"notes-app/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// reminderBatchSize limits how many due reminders are processed per tick
+const reminderBatchSize = 100
+
+// StartReminderScheduler fires due reminders every interval until stop is closed.
+// Reminder state lives in the database, so pending reminders survive restarts.
+func StartReminderScheduler(interval time.Duration, stop <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		// Catch up on anything that became due while the server was down
+		fireDueReminders()
+
+		for {
+			select {
+			case <-ticker.C:
+				fireDueReminders()
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	utils.LogInfo(fmt.Sprintf("Reminder scheduler started (interval %s)", interval))
+}
+
+// fireDueReminders delivers every reminder whose time has come
+func fireDueReminders() {
+	var reminders []models.Reminder
+	err := database.DB.Joins("JOIN notes ON notes.id = reminders.note_id AND notes.deleted_at IS NULL").
+		Where("reminders.fired_at IS NULL AND reminders.remind_at <= ?", time.Now()).
+		Order("reminders.remind_at").Limit(reminderBatchSize).
+		Find(&reminders).Error
+	if err != nil {
+		utils.LogError("Failed to load due reminders: " + err.Error())
+		return
+	}
+
+	for _, reminder := range reminders {
+		if err := fireReminder(reminder); err != nil {
+			utils.LogError(fmt.Sprintf("Failed to fire reminder %d: %v", reminder.ID, err))
+		}
+	}
+}
+
+// fireReminder claims a reminder and creates its notification in one transaction.
+// The conditional update makes sure a reminder is only delivered once, even when
+// several server processes poll at the same time or the reminder was just snoozed.
+func fireReminder(reminder models.Reminder) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&models.Reminder{}).
+			Where("id = ? AND fired_at IS NULL AND remind_at = ?", reminder.ID, reminder.RemindAt).
+			Update("fired_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			// Already fired elsewhere or rescheduled in the meantime
+			return nil
+		}
+
+		var note models.Note
+		if err := tx.Select("id", "title").First(&note, reminder.NoteID).Error; err != nil {
+			return err
+		}
+
+		noteID := note.ID
+		notification := models.Notification{
+			UserID:  reminder.UserID,
+			NoteID:  &noteID,
+			Type:    models.NotificationTypeReminder,
+			Message: fmt.Sprintf("Reminder: %s", note.Title),
+		}
+		if err := tx.Create(&notification).Error; err != nil {
+			return err
+		}
+
+		utils.LogInfo(fmt.Sprintf("Reminder fired: ID=%d, NoteID=%d, UserID=%d", reminder.ID, note.ID, reminder.UserID))
+		return nil
+	})
+}
@@ -0,0 +1,74 @@
+package scheduler
+
+import (
+	"fmt"
+	"notes-app/config"
+	"notes-app/database"
+	"notes-app/models"
+	"notes-app/utils"
+	"time"
+)
+
+// StartTokenCleanup deletes expired refresh tokens, revocation records,
+// password reset tokens, two-factor and WebAuthn challenges, unfinished
+// single sign-on logins and ended sessions every interval until stop is closed
+func StartTokenCleanup(interval time.Duration, stop <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		removeExpiredTokens()
+
+		for {
+			select {
+			case <-ticker.C:
+				removeExpiredTokens()
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	utils.LogInfo(fmt.Sprintf("Token cleanup started (interval %s)", interval))
+}
+
+// removeExpiredTokens deletes refresh tokens that can no longer be used and
+// revocation records of access tokens that have expired anyway
+func removeExpiredTokens() {
+	now := time.Now()
+
+	result := database.DB.Where("expires_at <= ?", now).Delete(&models.RefreshToken{})
+	if result.Error != nil {
+		utils.LogError("Failed to delete expired refresh tokens: " + result.Error.Error())
+	} else if result.RowsAffected > 0 {
+		utils.LogInfo(fmt.Sprintf("Removed %d expired refresh tokens", result.RowsAffected))
+	}
+
+	if err := database.DB.Where("expires_at <= ?", now).Delete(&models.RevokedToken{}).Error; err != nil {
+		utils.LogError("Failed to delete expired token revocations: " + err.Error())
+	}
+
+	if err := database.DB.Where("expires_at <= ?", now).Delete(&models.PasswordResetToken{}).Error; err != nil {
+		utils.LogError("Failed to delete expired password reset tokens: " + err.Error())
+	}
+
+	if err := database.DB.Where("expires_at <= ?", now).Delete(&models.TwoFactorChallenge{}).Error; err != nil {
+		utils.LogError("Failed to delete expired two-factor challenges: " + err.Error())
+	}
+
+	if err := database.DB.Where("expires_at <= ?", now).Delete(&models.WebAuthnChallenge{}).Error; err != nil {
+		utils.LogError("Failed to delete expired WebAuthn challenges: " + err.Error())
+	}
+
+	if err := database.DB.Where("expires_at <= ?", now).Delete(&models.OIDCLogin{}).Error; err != nil {
+		utils.LogError("Failed to delete expired single sign-on logins: " + err.Error())
+	}
+
+	// Access tokens of a revoked session are rejected once the session row is gone,
+	// so ended sessions only need to be kept until their access tokens expire
+	accessTTL := config.LoadConfig().AccessTokenTTL
+	err := database.DB.Where("expires_at <= ? OR revoked_at <= ?", now, now.Add(-accessTTL)).Delete(&models.Session{}).Error
+	if err != nil {
+		utils.LogError("Failed to delete ended sessions: " + err.Error())
+	}
+}
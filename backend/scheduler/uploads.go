@@ -0,0 +1,55 @@
+package scheduler
+
+import (
+	"fmt"
+	"notes-app/database"
+	"notes-app/models"
+	"notes-app/storage"
+	"notes-app/utils"
+	"time"
+)
+
+// StartUploadCleanup discards expired resumable uploads every interval until
+// stop is closed, deleting their chunks from storage
+func StartUploadCleanup(interval time.Duration, stop <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		removeExpiredUploads()
+
+		for {
+			select {
+			case <-ticker.C:
+				removeExpiredUploads()
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	utils.LogInfo(fmt.Sprintf("Upload cleanup started (interval %s)", interval))
+}
+
+// removeExpiredUploads deletes upload sessions that were never completed
+func removeExpiredUploads() {
+	var sessions []models.UploadSession
+	if err := database.DB.Where("expires_at <= ?", time.Now()).Find(&sessions).Error; err != nil {
+		utils.LogError("Failed to load expired uploads: " + err.Error())
+		return
+	}
+
+	for _, session := range sessions {
+		if err := database.DB.Delete(&session).Error; err != nil {
+			utils.LogError(fmt.Sprintf("Failed to delete expired upload %s: %s", session.ID, err.Error()))
+			continue
+		}
+		if err := storage.DeletePrefix(session.ChunkPrefix()); err != nil {
+			utils.LogError(fmt.Sprintf("Failed to delete chunks of upload %s: %s", session.ID, err.Error()))
+		}
+	}
+
+	if len(sessions) > 0 {
+		utils.LogInfo(fmt.Sprintf("Removed %d expired uploads", len(sessions)))
+	}
+}
@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LocalStorage stores objects as files below a directory on the local disk.
+// Files are not public: SignedURL returns an HMAC-signed, expiring URL below
+// urlPrefix that the application verifies with VerifySignature before serving.
+type LocalStorage struct {
+	root       string
+	urlPrefix  string
+	signingKey []byte
+}
+
+// NewLocalStorage creates a local filesystem storage rooted at dir whose files are served under urlPrefix
+func NewLocalStorage(dir, urlPrefix string, signingKey []byte) *LocalStorage {
+	return &LocalStorage{root: dir, urlPrefix: urlPrefix, signingKey: signingKey}
+}
+
+// Put writes the object to a temporary file and renames it into place
+func (s *LocalStorage) Put(key string, r io.Reader, size int64, contentType string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+// Get opens the object's file
+func (s *LocalStorage) Get(key string) (io.ReadCloser, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+// Delete removes the object's file
+func (s *LocalStorage) Delete(key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// List walks the storage directory, skipping temporary files of unfinished writes.
+// Only the directory holding keys with the given prefix is walked.
+func (s *LocalStorage) List(prefix string, fn func(Object) error) error {
+	start := s.root
+	if i := strings.LastIndexByte(prefix, '/'); i > 0 {
+		dir, err := cleanKey(prefix[:i])
+		if err != nil {
+			return err
+		}
+		start = filepath.Join(s.root, filepath.FromSlash(dir))
+	}
+
+	err := filepath.WalkDir(start, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".upload-") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.root, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return fn(Object{Key: key, Size: info.Size(), ModTime: info.ModTime()})
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// SignedURL returns a URL below urlPrefix carrying the expiry time and an HMAC of key and expiry
+func (s *LocalStorage) SignedURL(key string, expiry time.Duration) (string, error) {
+	if _, err := cleanKey(key); err != nil {
+		return "", err
+	}
+	expires := time.Now().Add(expiry).Unix()
+	return fmt.Sprintf("%s/%s?expires=%d&signature=%s", s.urlPrefix, key, expires, s.sign(key, expires)), nil
+}
+
+// VerifySignature reports whether signature is valid for key and has not expired
+func (s *LocalStorage) VerifySignature(key string, expires int64, signature string) bool {
+	if time.Now().Unix() > expires {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(s.sign(key, expires)))
+}
+
+// sign computes the URL signature of key valid until expires
+func (s *LocalStorage) sign(key string, expires int64) string {
+	h := hmac.New(sha256.New, s.signingKey)
+	fmt.Fprintf(h, "%s\n%d", key, expires)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// path returns the file path of a key
+func (s *LocalStorage) path(key string) (string, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"notes-app/config"
+	"notes-app/utils"
+	"path"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when a key does not exist in the storage backend
+var ErrNotFound = errors.New("storage: object not found")
+
+// Storage is a blob store for uploaded files, addressed by slash-separated keys
+type Storage interface {
+	// Put stores size bytes read from r under key
+	Put(key string, r io.Reader, size int64, contentType string) error
+	// Get opens the object stored under key
+	Get(key string) (io.ReadCloser, error)
+	// Delete removes the object stored under key; deleting a missing key is not an error
+	Delete(key string) error
+	// SignedURL returns a URL the client can use to fetch the object until expiry
+	SignedURL(key string, expiry time.Duration) (string, error)
+	// List calls fn for every stored object whose key starts with prefix
+	List(prefix string, fn func(Object) error) error
+}
+
+// Object describes a stored object
+type Object struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// Store is the storage backend selected in the configuration
+var Store Storage
+
+// urlExpiry is how long URLs returned by URL stay valid
+var urlExpiry = 15 * time.Minute
+
+// Setup initializes Store from the configuration
+func Setup(cfg *config.Config) error {
+	switch cfg.StorageBackend {
+	case "local":
+		Store = NewLocalStorage(cfg.UploadDir, "/api/files", []byte(cfg.StorageSigningKey))
+	case "s3":
+		s3, err := NewS3Storage(S3Options{
+			Endpoint:     cfg.S3Endpoint,
+			Region:       cfg.S3Region,
+			Bucket:       cfg.S3Bucket,
+			AccessKey:    cfg.S3AccessKey,
+			SecretKey:    cfg.S3SecretKey,
+			UsePathStyle: cfg.S3UsePathStyle,
+		})
+		if err != nil {
+			return err
+		}
+		Store = s3
+	default:
+		return fmt.Errorf("storage: unknown backend %q", cfg.StorageBackend)
+	}
+	urlExpiry = cfg.SignedURLExpiry
+	return nil
+}
+
+// URL returns a signed URL for key using the configured expiry, or "" if key is empty
+func URL(key string) string {
+	if key == "" {
+		return ""
+	}
+	u, err := Store.SignedURL(key, urlExpiry)
+	if err != nil {
+		utils.LogError(fmt.Sprintf("Failed to sign URL for %s: %v", key, err))
+		return ""
+	}
+	return u
+}
+
+// DeletePrefix removes every object whose key starts with prefix
+func DeletePrefix(prefix string) error {
+	var keys []string
+	err := Store.List(prefix, func(obj Object) error {
+		keys = append(keys, obj.Key)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := Store.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cleanKey validates a storage key and rejects keys escaping the storage root
+func cleanKey(key string) (string, error) {
+	cleaned := path.Clean("/" + key)[1:]
+	if cleaned == "" || cleaned != key || strings.Contains(key, "\\") {
+		return "", fmt.Errorf("storage: invalid key %q", key)
+	}
+	return cleaned, nil
+}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"fmt"
+	"notes-app/config"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Purposes of email tokens; a token is only accepted for the purpose it was issued for
+const (
+	EmailTokenVerify = "verify-email"
+	EmailTokenChange = "change-email"
+)
+
+// EmailClaims represents the claims of a signed token sent by email to prove
+// ownership of an address
+type EmailClaims struct {
+	UserID       uint   `json:"user_id"`
+	Email        string `json:"email"` // the address the token was sent to
+	Purpose      string `json:"purpose"`
+	CurrentEmail string `json:"current_email,omitempty"` // for email changes, the address being replaced
+	SessionID    string `json:"sid,omitempty"`           // for email changes, the session that asked for it
+	jwt.RegisteredClaims
+}
+
+// GenerateEmailToken signs claims into a token proving that whoever holds it
+// received mail at claims.Email, valid for ttl
+func GenerateEmailToken(claims EmailClaims, ttl time.Duration) (string, error) {
+	cfg := config.LoadConfig()
+
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(cfg.JWTSecret))
+}
+
+// ValidateEmailToken validates an email token issued for purpose and returns its claims
+func ValidateEmailToken(tokenString, purpose string) (*EmailClaims, error) {
+	cfg := config.LoadConfig()
+
+	token, err := jwt.ParseWithClaims(tokenString, &EmailClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(cfg.JWTSecret), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(*EmailClaims)
+	if !ok || !token.Valid || claims.Purpose != purpose {
+		return nil, fmt.Errorf("invalid token")
+	}
+	return claims, nil
+}
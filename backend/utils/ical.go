@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CalendarEntry represents a dated item rendered into an iCalendar feed
+type CalendarEntry struct {
+	UID         string
+	Summary     string
+	Description string
+	Due         time.Time
+	Modified    time.Time
+	Alarms      []time.Time
+}
+
+const icalTimeFormat = "20060102T150405Z"
+
+// RenderICalendar renders entries as an RFC 5545 VCALENDAR.
+// Entries become VTODO components when asTodo is set and VEVENT components otherwise.
+// All times are written in UTC so clients convert them to their own time zone.
+func RenderICalendar(name string, entries []CalendarEntry, asTodo bool) string {
+	var sb strings.Builder
+	now := time.Now().UTC().Format(icalTimeFormat)
+
+	writeICalLine(&sb, "BEGIN:VCALENDAR")
+	writeICalLine(&sb, "VERSION:2.0")
+	writeICalLine(&sb, "PRODID:-//Notes Sharing App//Notes Calendar//EN")
+	writeICalLine(&sb, "CALSCALE:GREGORIAN")
+	writeICalLine(&sb, "METHOD:PUBLISH")
+	writeICalLine(&sb, "X-WR-CALNAME:"+escapeICalText(name))
+
+	component := "VEVENT"
+	if asTodo {
+		component = "VTODO"
+	}
+
+	for _, entry := range entries {
+		due := entry.Due.UTC().Format(icalTimeFormat)
+
+		writeICalLine(&sb, "BEGIN:"+component)
+		writeICalLine(&sb, "UID:"+entry.UID)
+		writeICalLine(&sb, "DTSTAMP:"+now)
+		if !entry.Modified.IsZero() {
+			writeICalLine(&sb, "LAST-MODIFIED:"+entry.Modified.UTC().Format(icalTimeFormat))
+		}
+		if asTodo {
+			writeICalLine(&sb, "DUE:"+due)
+		} else {
+			// Without DTEND or DURATION a date-time DTSTART is an event that
+			// takes up no time (RFC 5545 section 3.6.1); DTEND equal to
+			// DTSTART isn't valid
+			writeICalLine(&sb, "DTSTART:"+due)
+		}
+		writeICalLine(&sb, "SUMMARY:"+escapeICalText(entry.Summary))
+		if entry.Description != "" {
+			writeICalLine(&sb, "DESCRIPTION:"+escapeICalText(entry.Description))
+		}
+		for _, alarm := range entry.Alarms {
+			writeICalLine(&sb, "BEGIN:VALARM")
+			writeICalLine(&sb, "ACTION:DISPLAY")
+			writeICalLine(&sb, "DESCRIPTION:"+escapeICalText(entry.Summary))
+			writeICalLine(&sb, "TRIGGER;VALUE=DATE-TIME:"+alarm.UTC().Format(icalTimeFormat))
+			writeICalLine(&sb, "END:VALARM")
+		}
+		writeICalLine(&sb, "END:"+component)
+	}
+
+	writeICalLine(&sb, "END:VCALENDAR")
+	return sb.String()
+}
+
+// escapeICalText escapes a TEXT property value
+func escapeICalText(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, ";", `\;`)
+	s = strings.ReplaceAll(s, ",", `\,`)
+	s = strings.ReplaceAll(s, "\r\n", `\n`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return s
+}
+
+// writeICalLine writes a content line folded at 75 octets and terminated with CRLF
+func writeICalLine(sb *strings.Builder, line string) {
+	limit := 75
+	for len(line) > limit {
+		// Don't split a multi-byte UTF-8 sequence
+		cut := limit
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		sb.WriteString(line[:cut])
+		sb.WriteString("\r\n ")
+		line = line[cut:]
+		// Continuation lines start with a space that counts towards the limit
+		limit = 74
+	}
+	fmt.Fprintf(sb, "%s\r\n", line)
+}
@@ -10,21 +10,32 @@ import (
 
 // Claims represents the JWT claims
 type Claims struct {
-	UserID uint   `json:"user_id"`
-	Email  string `json:"email"`
+	UserID     uint   `json:"user_id"`
+	Email      string `json:"email"`
+	Generation int    `json:"gen"` // the user's token generation when the token was issued
+	SessionID  string `json:"sid"` // the session (refresh token family) the token belongs to
 	jwt.RegisteredClaims
 }
 
-// GenerateToken generates a JWT token for a user
-func GenerateToken(userID uint, email string) (string, error) {
+// GenerateToken generates a short-lived JWT access token for a user
+func GenerateToken(userID uint, email string, generation int, sessionID string) (string, error) {
 	cfg := config.LoadConfig()
 
-	// Create claims with expiration time (24 hours)
+	// Unique token ID (jti), so the token can be revoked on its own
+	jti, err := GenerateSecureToken(16)
+	if err != nil {
+		return "", err
+	}
+
+	// Create claims with expiration time (ACCESS_TOKEN_TTL)
 	claims := Claims{
-		UserID: userID,
-		Email:  email,
+		UserID:     userID,
+		Email:      email,
+		Generation: generation,
+		SessionID:  sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+			ID:        jti,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(cfg.AccessTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"bytes"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// Formats that share a container, keyed by extension. Content sniffing only
+// recognizes the container, so the extension picks the specific type.
+var (
+	zipMIMETypes = map[string]string{
+		".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+		".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		".pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+		".odt":  "application/vnd.oasis.opendocument.text",
+		".ods":  "application/vnd.oasis.opendocument.spreadsheet",
+		".odp":  "application/vnd.oasis.opendocument.presentation",
+	}
+	oleMIMETypes = map[string]string{
+		".doc": "application/msword",
+		".xls": "application/vnd.ms-excel",
+		".ppt": "application/vnd.ms-powerpoint",
+	}
+	textMIMETypes = map[string]string{
+		".md":  "text/markdown",
+		".csv": "text/csv",
+	}
+)
+
+var oleSignature = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+
+// DetectMIME determines a file's MIME type from its first bytes. The
+// extension is only used to tell apart formats sharing a container, and
+// only when the content matches that container.
+func DetectMIME(head []byte, filename string) string {
+	detected := http.DetectContentType(head)
+	if i := strings.IndexByte(detected, ';'); i >= 0 {
+		detected = detected[:i]
+	}
+
+	ext := strings.ToLower(filepath.Ext(filename))
+	switch {
+	case detected == "application/zip" && zipMIMETypes[ext] != "":
+		return zipMIMETypes[ext]
+	case bytes.HasPrefix(head, oleSignature) && oleMIMETypes[ext] != "":
+		return oleMIMETypes[ext]
+	case detected == "text/plain" && textMIMETypes[ext] != "":
+		return textMIMETypes[ext]
+	}
+	return detected
+}
@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"regexp"
+	"sort"
+)
+
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.\-]+)\s*\}\}`)
+
+// RenderTemplate replaces {{name}} placeholders with values from vars.
+// Placeholders without a value are left untouched and returned as missing.
+func RenderTemplate(text string, vars map[string]string) (string, []string) {
+	missingSet := make(map[string]bool)
+
+	rendered := placeholderPattern.ReplaceAllStringFunc(text, func(match string) string {
+		name := placeholderPattern.FindStringSubmatch(match)[1]
+		if value, ok := vars[name]; ok {
+			return value
+		}
+		missingSet[name] = true
+		return match
+	})
+
+	missing := make([]string, 0, len(missingSet))
+	for name := range missingSet {
+		missing = append(missing, name)
+	}
+	sort.Strings(missing)
+
+	return rendered, missing
+}
@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 seed of RFC 4226 Appendix D and RFC 6238 Appendix B,
+// "12345678901234567890", base32 encoded
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestHOTPVectors(t *testing.T) {
+	// RFC 4226 Appendix D
+	want := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+	for counter, code := range want {
+		if got := totpCode([]byte("12345678901234567890"), int64(counter)); got != code {
+			t.Errorf("counter %d: got %s, want %s", counter, got, code)
+		}
+	}
+}
+
+func TestValidateTOTPVectors(t *testing.T) {
+	// RFC 6238 Appendix B, SHA-1 rows; the vectors have 8 digits, and a
+	// 6-digit code is their last six
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+	for _, tt := range tests {
+		code := tt.code[2:]
+		step, ok := ValidateTOTP(rfcSecret, code, time.Unix(tt.unix, 0))
+		if !ok || step != tt.unix/totpPeriod {
+			t.Errorf("T=%d code %s: got step %d, %v; want step %d", tt.unix, code, step, ok, tt.unix/totpPeriod)
+		}
+	}
+}
+
+func TestValidateTOTPSkew(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	current := now.Unix() / totpPeriod
+	key := []byte("12345678901234567890")
+
+	for offset := int64(-2); offset <= 2; offset++ {
+		step, ok := ValidateTOTP(rfcSecret, totpCode(key, current+offset), now)
+		wantOK := offset >= -totpSkew && offset <= totpSkew
+		if ok != wantOK || (ok && step != current+offset) {
+			t.Errorf("code %+d steps away: got step %d, %v; want accepted=%v", offset, step, ok, wantOK)
+		}
+	}
+}
+
+func TestValidateTOTPRejected(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+	}{
+		{"wrong code", rfcSecret, "005925"},
+		{"empty", rfcSecret, ""},
+		{"too short", rfcSecret, "05924"},
+		{"too long", rfcSecret, "0005924"},
+		{"eight digits", rfcSecret, "89005924"},
+		{"invalid secret", "not base32!", "005924"},
+		{"other secret", "JBSWY3DPEHPK3PXP", "005924"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := ValidateTOTP(tt.secret, tt.code, now); ok {
+				t.Fatalf("code %q accepted", tt.code)
+			}
+		})
+	}
+}
+
+func TestValidateTOTPSecretFormatting(t *testing.T) {
+	// Secrets are accepted regardless of case and surrounding whitespace
+	if _, ok := ValidateTOTP(" gezdgnbvgy3tqojqgezdgnbvgy3tqojq\n", "005924", time.Unix(1234567890, 0)); !ok {
+		t.Fatal("lower-case secret rejected")
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil || len(key) != 20 {
+		t.Fatalf("secret %q doesn't decode to 160 bits: %v", secret, err)
+	}
+
+	// A freshly generated secret validates its own current code
+	now := time.Now()
+	if _, ok := ValidateTOTP(secret, totpCode(key, now.Unix()/totpPeriod), now); !ok {
+		t.Fatal("current code rejected")
+	}
+}
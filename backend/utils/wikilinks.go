@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// WikiLink represents a [[...]] reference found in note content
+type WikiLink struct {
+	NoteID uint   // set for [[note:123]] links
+	Title  string // set for [[Note Title]] links
+}
+
+var wikiLinkPattern = regexp.MustCompile(`\[\[([^\[\]\n]+)\]\]`)
+
+// ParseWikiLinks extracts unique [[Note Title]] and [[note:123]] links from content
+func ParseWikiLinks(content string) []WikiLink {
+	var links []WikiLink
+	seen := make(map[string]bool)
+
+	for _, match := range wikiLinkPattern.FindAllStringSubmatch(content, -1) {
+		target := strings.TrimSpace(match[1])
+		if target == "" {
+			continue
+		}
+
+		var link WikiLink
+		if strings.HasPrefix(strings.ToLower(target), "note:") {
+			id, err := strconv.ParseUint(strings.TrimSpace(target[len("note:"):]), 10, 64)
+			if err != nil || id == 0 {
+				continue
+			}
+			link.NoteID = uint(id)
+		} else {
+			link.Title = target
+		}
+
+		key := strings.ToLower(link.Title) + "#" + strconv.FormatUint(uint64(link.NoteID), 10)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		links = append(links, link)
+	}
+
+	return links
+}
@@ -0,0 +1,461 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+const (
+	testRPID   = "notes.example.com"
+	testOrigin = "https://notes.example.com"
+)
+
+var testConfig = &Config{RPID: testRPID, RPName: "Notes", Origins: []string{testOrigin}}
+
+// Minimal CBOR encoder for building authenticator responses
+
+func cborHead(major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return []byte{major<<5 | byte(n)}
+	case n <= 0xff:
+		return []byte{major<<5 | 24, byte(n)}
+	case n <= 0xffff:
+		return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(n))
+	case n <= 0xffffffff:
+		return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(n))
+	}
+	return binary.BigEndian.AppendUint64([]byte{major<<5 | 27}, n)
+}
+
+func cborInt(v int64) []byte {
+	if v < 0 {
+		return cborHead(1, uint64(-1-v))
+	}
+	return cborHead(0, uint64(v))
+}
+
+func cborBytes(b []byte) []byte { return append(cborHead(2, uint64(len(b))), b...) }
+func cborText(s string) []byte  { return append(cborHead(3, uint64(len(s))), s...) }
+
+// cborMap encodes alternating keys and values that are already encoded
+func cborMap(items ...[]byte) []byte {
+	out := cborHead(5, uint64(len(items)/2))
+	for _, item := range items {
+		out = append(out, item...)
+	}
+	return out
+}
+
+// softAuthenticator is a software authenticator holding one credential
+type softAuthenticator struct {
+	alg       int64
+	signer    crypto.Signer
+	credID    []byte
+	rpID      string
+	signCount uint32
+	flags     byte
+}
+
+func newSoftAuthenticator(t *testing.T, alg int64) *softAuthenticator {
+	t.Helper()
+	var signer crypto.Signer
+	var err error
+	switch alg {
+	case AlgES256:
+		signer, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgEdDSA:
+		_, signer, err = ed25519.GenerateKey(rand.Reader)
+	case AlgRS256:
+		signer, err = rsa.GenerateKey(rand.Reader, 2048)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	credID := make([]byte, 16)
+	rand.Read(credID)
+	return &softAuthenticator{
+		alg:    alg,
+		signer: signer,
+		credID: credID,
+		rpID:   testRPID,
+		flags:  flagUserPresent | flagUserVerified,
+	}
+}
+
+// coseKey encodes the credential public key as a COSE_Key
+func (a *softAuthenticator) coseKey() []byte {
+	switch pub := a.signer.Public().(type) {
+	case *ecdsa.PublicKey:
+		return cborMap(
+			cborInt(coseKty), cborInt(ktyEC2),
+			cborInt(coseAlg), cborInt(AlgES256),
+			cborInt(coseCrv), cborInt(crvP256),
+			cborInt(coseX), cborBytes(pub.X.FillBytes(make([]byte, 32))),
+			cborInt(coseY), cborBytes(pub.Y.FillBytes(make([]byte, 32))),
+		)
+	case ed25519.PublicKey:
+		return cborMap(
+			cborInt(coseKty), cborInt(ktyOKP),
+			cborInt(coseAlg), cborInt(AlgEdDSA),
+			cborInt(coseCrv), cborInt(crvEd25519),
+			cborInt(coseX), cborBytes(pub),
+		)
+	case *rsa.PublicKey:
+		return cborMap(
+			cborInt(coseKty), cborInt(ktyRSA),
+			cborInt(coseAlg), cborInt(AlgRS256),
+			cborInt(coseCrv), cborBytes(pub.N.Bytes()),
+			cborInt(coseX), cborBytes(big.NewInt(int64(pub.E)).Bytes()),
+		)
+	}
+	panic("unknown key type")
+}
+
+func (a *softAuthenticator) authData(flags byte, attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.rpID))
+	data := append(rpIDHash[:], flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	if attested {
+		data = append(data, make([]byte, 16)...) // AAGUID
+		data = binary.BigEndian.AppendUint16(data, uint16(len(a.credID)))
+		data = append(data, a.credID...)
+		data = append(data, a.coseKey()...)
+	}
+	return data
+}
+
+func clientDataJSON(ceremony string, challenge []byte, origin string) []byte {
+	data, _ := json.Marshal(clientData{
+		Type:      ceremony,
+		Challenge: base64.RawURLEncoding.EncodeToString(challenge),
+		Origin:    origin,
+	})
+	return data
+}
+
+// create answers navigator.credentials.create()
+func (a *softAuthenticator) create(challenge []byte) (clientData, attestationObject []byte) {
+	clientData = clientDataJSON("webauthn.create", challenge, testOrigin)
+	attestationObject = cborMap(
+		cborText("fmt"), cborText("none"),
+		cborText("attStmt"), cborMap(),
+		cborText("authData"), cborBytes(a.authData(a.flags|flagAttestedCredData, true)),
+	)
+	return clientData, attestationObject
+}
+
+// get answers navigator.credentials.get(), counting the signature
+func (a *softAuthenticator) get(t *testing.T, challenge []byte, origin string) (clientData, authData, signature []byte) {
+	t.Helper()
+	a.signCount++
+	clientData = clientDataJSON("webauthn.get", challenge, origin)
+	authData = a.authData(a.flags, false)
+	return clientData, authData, a.sign(t, authData, clientData)
+}
+
+func (a *softAuthenticator) sign(t *testing.T, authData, clientData []byte) []byte {
+	t.Helper()
+	clientDataHash := sha256.Sum256(clientData)
+	signed := append(append([]byte(nil), authData...), clientDataHash[:]...)
+
+	var sig []byte
+	var err error
+	if a.alg == AlgEdDSA {
+		sig, err = a.signer.Sign(rand.Reader, signed, crypto.Hash(0))
+	} else {
+		digest := sha256.Sum256(signed)
+		sig, err = a.signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sig
+}
+
+func challenge() []byte {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return b
+}
+
+var algorithms = []struct {
+	name string
+	alg  int64
+}{
+	{"ES256", AlgES256},
+	{"EdDSA", AlgEdDSA},
+	{"RS256", AlgRS256},
+}
+
+func register(t *testing.T, a *softAuthenticator) *Credential {
+	t.Helper()
+	c := challenge()
+	clientData, attestationObject := a.create(c)
+	cred, err := testConfig.VerifyRegistration(c, clientData, attestationObject)
+	if err != nil {
+		t.Fatalf("registration failed: %v", err)
+	}
+	return cred
+}
+
+func TestRegistrationAndAssertion(t *testing.T) {
+	for _, tt := range algorithms {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newSoftAuthenticator(t, tt.alg)
+			cred := register(t, a)
+			if !bytes.Equal(cred.ID, a.credID) || !cred.UserVerified {
+				t.Fatalf("unexpected credential: %+v", cred)
+			}
+
+			signCount := cred.SignCount
+			for i := 0; i < 2; i++ {
+				c := challenge()
+				clientData, authData, sig := a.get(t, c, testOrigin)
+				assertion, err := testConfig.VerifyAssertion(c, clientData, authData, sig, cred.PublicKey, signCount)
+				if err != nil {
+					t.Fatalf("assertion %d failed: %v", i, err)
+				}
+				if assertion.SignCount != a.signCount || !assertion.UserVerified {
+					t.Fatalf("unexpected assertion: %+v", assertion)
+				}
+				signCount = assertion.SignCount
+			}
+		})
+	}
+}
+
+func TestAssertionWithoutUserVerification(t *testing.T) {
+	a := newSoftAuthenticator(t, AlgES256)
+	cred := register(t, a)
+	a.flags = flagUserPresent
+
+	c := challenge()
+	clientData, authData, sig := a.get(t, c, testOrigin)
+	assertion, err := testConfig.VerifyAssertion(c, clientData, authData, sig, cred.PublicKey, cred.SignCount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assertion.UserVerified {
+		t.Fatal("assertion without the UV flag reported as user verified")
+	}
+}
+
+func TestAssertionRejected(t *testing.T) {
+	for _, tt := range algorithms {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newSoftAuthenticator(t, tt.alg)
+			cred := register(t, a)
+
+			tests := []struct {
+				name   string
+				modify func(c, clientData, authData, sig []byte) ([]byte, []byte, []byte, []byte)
+			}{
+				{"wrong origin", func(c, _, _, _ []byte) ([]byte, []byte, []byte, []byte) {
+					clientData, authData, sig := a.get(t, c, "https://evil.example.com")
+					return c, clientData, authData, sig
+				}},
+				{"wrong challenge", func(_, clientData, authData, sig []byte) ([]byte, []byte, []byte, []byte) {
+					return challenge(), clientData, authData, sig
+				}},
+				{"wrong ceremony", func(c, _, authData, _ []byte) ([]byte, []byte, []byte, []byte) {
+					clientData := clientDataJSON("webauthn.create", c, testOrigin)
+					return c, clientData, authData, a.sign(t, authData, clientData)
+				}},
+				{"wrong RP ID", func(c, _, _, _ []byte) ([]byte, []byte, []byte, []byte) {
+					a.rpID = "evil.example.com"
+					defer func() { a.rpID = testRPID }()
+					clientData, authData, sig := a.get(t, c, testOrigin)
+					return c, clientData, authData, sig
+				}},
+				{"user not present", func(c, _, _, _ []byte) ([]byte, []byte, []byte, []byte) {
+					a.flags = 0
+					defer func() { a.flags = flagUserPresent | flagUserVerified }()
+					clientData, authData, sig := a.get(t, c, testOrigin)
+					return c, clientData, authData, sig
+				}},
+				{"tampered authenticator data", func(c, clientData, authData, sig []byte) ([]byte, []byte, []byte, []byte) {
+					authData = append([]byte(nil), authData...)
+					authData[32] |= flagBackupEligible
+					return c, clientData, authData, sig
+				}},
+				{"tampered signature", func(c, clientData, authData, sig []byte) ([]byte, []byte, []byte, []byte) {
+					sig = append([]byte(nil), sig...)
+					sig[len(sig)-1] ^= 0x01
+					return c, clientData, authData, sig
+				}},
+				{"trailing bytes", func(c, clientData, authData, _ []byte) ([]byte, []byte, []byte, []byte) {
+					authData = append(append([]byte(nil), authData...), 0x00)
+					return c, clientData, authData, a.sign(t, authData, clientData)
+				}},
+			}
+
+			for _, tc := range tests {
+				t.Run(tc.name, func(t *testing.T) {
+					c := challenge()
+					clientData, authData, sig := a.get(t, c, testOrigin)
+					c, clientData, authData, sig = tc.modify(c, clientData, authData, sig)
+					if _, err := testConfig.VerifyAssertion(c, clientData, authData, sig, cred.PublicKey, 0); err == nil {
+						t.Fatal("assertion accepted")
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestAssertionSignCountRollback(t *testing.T) {
+	a := newSoftAuthenticator(t, AlgES256)
+	cred := register(t, a)
+
+	c := challenge()
+	clientData, authData, sig := a.get(t, c, testOrigin)
+	assertion, err := testConfig.VerifyAssertion(c, clientData, authData, sig, cred.PublicKey, cred.SignCount)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A clone replaying the same counter, or one behind it, is rejected
+	for _, stored := range []uint32{assertion.SignCount, assertion.SignCount + 5} {
+		c := challenge()
+		a.signCount = assertion.SignCount - 1
+		clientData, authData, sig := a.get(t, c, testOrigin)
+		_, err := testConfig.VerifyAssertion(c, clientData, authData, sig, cred.PublicKey, stored)
+		if !errors.Is(err, ErrSignCount) {
+			t.Fatalf("stored count %d: got %v, want ErrSignCount", stored, err)
+		}
+	}
+
+	// Authenticators without a counter always report zero, which is fine
+	a.signCount = 0
+	c = challenge()
+	clientData = clientDataJSON("webauthn.get", c, testOrigin)
+	authData = a.authData(a.flags, false)
+	if _, err := testConfig.VerifyAssertion(c, clientData, authData, a.sign(t, authData, clientData), cred.PublicKey, 0); err != nil {
+		t.Fatalf("zero counter rejected: %v", err)
+	}
+}
+
+func TestRegistrationRejected(t *testing.T) {
+	a := newSoftAuthenticator(t, AlgES256)
+
+	t.Run("wrong challenge", func(t *testing.T) {
+		clientData, attestationObject := a.create(challenge())
+		if _, err := testConfig.VerifyRegistration(challenge(), clientData, attestationObject); err == nil {
+			t.Fatal("registration accepted")
+		}
+	})
+
+	t.Run("wrong RP ID", func(t *testing.T) {
+		a.rpID = "evil.example.com"
+		defer func() { a.rpID = testRPID }()
+		c := challenge()
+		clientData, attestationObject := a.create(c)
+		if _, err := testConfig.VerifyRegistration(c, clientData, attestationObject); err == nil {
+			t.Fatal("registration accepted")
+		}
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		c := challenge()
+		clientData, attestationObject := a.create(c)
+		for n := 0; n < len(attestationObject); n++ {
+			if _, err := testConfig.VerifyRegistration(c, clientData, attestationObject[:n]); err == nil {
+				t.Fatalf("registration truncated to %d bytes accepted", n)
+			}
+		}
+	})
+}
+
+func TestParsePublicKeyTruncated(t *testing.T) {
+	for _, tt := range algorithms {
+		t.Run(tt.name, func(t *testing.T) {
+			key := newSoftAuthenticator(t, tt.alg).coseKey()
+			if _, err := ParsePublicKey(key); err != nil {
+				t.Fatal(err)
+			}
+			for n := 0; n < len(key); n++ {
+				if _, err := ParsePublicKey(key[:n]); err == nil {
+					t.Fatalf("key truncated to %d bytes accepted", n)
+				}
+			}
+			if _, err := ParsePublicKey(append(key, 0x00)); err == nil {
+				t.Fatal("key with trailing bytes accepted")
+			}
+		})
+	}
+}
+
+func TestParsePublicKeyRejectsBadKeys(t *testing.T) {
+	tests := map[string][]byte{
+		"point not on curve": cborMap(
+			cborInt(coseKty), cborInt(ktyEC2),
+			cborInt(coseAlg), cborInt(AlgES256),
+			cborInt(coseCrv), cborInt(crvP256),
+			cborInt(coseX), cborBytes(make([]byte, 32)),
+			cborInt(coseY), cborBytes(bytes.Repeat([]byte{1}, 32)),
+		),
+		"short RSA modulus": cborMap(
+			cborInt(coseKty), cborInt(ktyRSA),
+			cborInt(coseAlg), cborInt(AlgRS256),
+			cborInt(coseCrv), cborBytes(bytes.Repeat([]byte{0xff}, 128)),
+			cborInt(coseX), cborBytes([]byte{1, 0, 1}),
+		),
+		"algorithm doesn't match key type": cborMap(
+			cborInt(coseKty), cborInt(ktyOKP),
+			cborInt(coseAlg), cborInt(AlgES256),
+			cborInt(coseCrv), cborInt(crvEd25519),
+			cborInt(coseX), cborBytes(make([]byte, 32)),
+		),
+		"not a map": cborBytes(make([]byte, 32)),
+	}
+	for name, key := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParsePublicKey(key); err == nil {
+				t.Fatal("key accepted")
+			}
+		})
+	}
+}
+
+func TestDecodeCBORHostile(t *testing.T) {
+	deep := bytes.Repeat([]byte{0x81}, maxCBORDepth+2) // nested one-element arrays
+	deep = append(deep, 0x00)
+
+	tests := map[string][]byte{
+		"empty":                      {},
+		"huge byte string length":    {0x5b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		"byte string past the end":   {0x45, 0x01, 0x02},
+		"huge array":                 {0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		"huge map":                   {0xbb, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00},
+		"indefinite length":          {0x5f, 0x41, 0x00, 0xff},
+		"truncated argument":         {0x19, 0x01},
+		"reserved additional info":   {0x1c},
+		"map with byte string key":   {0xa1, 0x41, 0x00, 0x00},
+		"map missing value":          {0xa1, 0x01},
+		"integer overflowing int64":  {0x1b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		"negative overflowing int64": {0x3b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		"truncated float":            {0xfb, 0x00, 0x00},
+		"nesting too deep":           deep,
+		"tag loop":                   bytes.Repeat([]byte{0xc6}, 64),
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, _, err := decodeCBOR(data); err == nil {
+				t.Fatal("decoded hostile input")
+			}
+		})
+	}
+}
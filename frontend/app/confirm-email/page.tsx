@@ -0,0 +1,62 @@
+'use client'
+
+import { Suspense, useEffect, useState } from 'react'
+import { useSearchParams } from 'next/navigation'
+import Link from 'next/link'
+import { confirmEmailChange } from '@/lib/api'
+
+function ConfirmEmailStatus() {
+  const searchParams = useSearchParams()
+  const token = searchParams.get('token') || ''
+  const [message, setMessage] = useState('')
+  const [error, setError] = useState('')
+
+  useEffect(() => {
+    if (!token) {
+      setError('This confirmation link is invalid.')
+      return
+    }
+
+    confirmEmailChange(token)
+      .then((data) => setMessage(data.message))
+      .catch((err: any) => setError(err.message || 'Failed to confirm email change'))
+  }, [token])
+
+  return (
+    <div className="min-h-screen flex items-center justify-center bg-gradient-to-br from-blue-500 to-purple-600 p-4">
+      <div className="bg-white rounded-lg shadow-xl p-8 w-full max-w-md">
+        <h1 className="text-3xl font-bold text-center mb-6">Confirm Email</h1>
+
+        {!message && !error && (
+          <p className="text-gray-600 text-center">Confirming your new email address...</p>
+        )}
+
+        {message && (
+          <div className="bg-green-50 border border-green-200 text-green-700 px-4 py-3 rounded mb-4">
+            {message}
+          </div>
+        )}
+
+        {error && (
+          <div className="bg-red-50 border border-red-200 text-red-700 px-4 py-3 rounded mb-4">
+            {error}
+          </div>
+        )}
+
+        <p className="text-center mt-6 text-gray-600">
+          <Link href="/notes" className="text-blue-600 hover:underline font-semibold">
+            Go to my notes
+          </Link>
+        </p>
+      </div>
+    </div>
+  )
+}
+
+export default function ConfirmEmail() {
+  return (
+    <Suspense>
+      <ConfirmEmailStatus />
+    </Suspense>
+  )
+}
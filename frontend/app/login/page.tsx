@@ -1,9 +1,9 @@
 'use client'
 
-import { useState } from 'react'
+import { useEffect, useState } from 'react'
 import { useRouter } from 'next/navigation'
 import Link from 'next/link'
-import { login } from '@/lib/api'
+import { beginOIDCLogin, getOIDCProviders, login, loginWithPasskey, verifyTwoFactor } from '@/lib/api'
 
 export default function Login() {
   const router = useRouter()
@@ -13,6 +13,13 @@ export default function Login() {
   })
   const [error, setError] = useState('')
   const [loading, setLoading] = useState(false)
+  const [challengeToken, setChallengeToken] = useState('')
+  const [code, setCode] = useState('')
+  const [providers, setProviders] = useState<{ id: string; name: string }[]>([])
+
+  useEffect(() => {
+    getOIDCProviders().then(setProviders)
+  }, [])
 
   const handleSubmit = async (e: React.FormEvent) => {
     e.preventDefault()
@@ -20,7 +27,11 @@ export default function Login() {
     setLoading(true)
 
     try {
-      await login(formData.email, formData.password)
+      const data = await login(formData.email, formData.password)
+      if (data.two_factor_required) {
+        setChallengeToken(data.challenge_token)
+        return
+      }
       router.push('/notes')
     } catch (err: any) {
       setError(err.message || 'Login failed')
@@ -29,6 +40,96 @@ export default function Login() {
     }
   }
 
+  const handlePasskey = async () => {
+    setError('')
+    setLoading(true)
+
+    try {
+      const data = await loginWithPasskey()
+      if (data.two_factor_required) {
+        setChallengeToken(data.challenge_token)
+        return
+      }
+      router.push('/notes')
+    } catch (err: any) {
+      setError(err.message || 'Passkey login failed')
+    } finally {
+      setLoading(false)
+    }
+  }
+
+  const handleSSO = async (provider: string) => {
+    setError('')
+    setLoading(true)
+
+    try {
+      await beginOIDCLogin(provider)
+    } catch (err: any) {
+      setError(err.message || 'Single sign-on failed')
+      setLoading(false)
+    }
+  }
+
+  const handleVerify = async (e: React.FormEvent) => {
+    e.preventDefault()
+    setError('')
+    setLoading(true)
+
+    try {
+      await verifyTwoFactor(challengeToken, code)
+      router.push('/notes')
+    } catch (err: any) {
+      setError(err.message || 'Verification failed')
+    } finally {
+      setLoading(false)
+    }
+  }
+
+  if (challengeToken) {
+    return (
+      <div className="min-h-screen flex items-center justify-center bg-gradient-to-br from-blue-500 to-purple-600 p-4">
+        <div className="bg-white rounded-lg shadow-xl p-8 w-full max-w-md">
+          <h1 className="text-3xl font-bold text-center mb-2">Two-Factor Authentication</h1>
+          <p className="text-gray-600 text-center mb-6">
+            Enter the code from your authenticator app, or one of your recovery codes
+          </p>
+
+          {error && (
+            <div className="bg-red-50 border border-red-200 text-red-700 px-4 py-3 rounded mb-4">
+              {error}
+            </div>
+          )}
+
+          <form onSubmit={handleVerify} className="space-y-4">
+            <div>
+              <label className="block text-sm font-medium text-gray-700 mb-1">
+                Code
+              </label>
+              <input
+                type="text"
+                required
+                autoFocus
+                autoComplete="one-time-code"
+                value={code}
+                onChange={(e) => setCode(e.target.value)}
+                className="w-full px-4 py-2 border border-gray-300 rounded-lg focus:ring-2 focus:ring-blue-500 focus:border-transparent"
+                placeholder="123456"
+              />
+            </div>
+
+            <button
+              type="submit"
+              disabled={loading}
+              className="w-full bg-blue-600 text-white py-2 rounded-lg font-semibold hover:bg-blue-700 transition disabled:opacity-50 disabled:cursor-not-allowed"
+            >
+              {loading ? 'Verifying...' : 'Verify'}
+            </button>
+          </form>
+        </div>
+      </div>
+    )
+  }
+
   return (
     <div className="min-h-screen flex items-center justify-center bg-gradient-to-br from-blue-500 to-purple-600 p-4">
       <div className="bg-white rounded-lg shadow-xl p-8 w-full max-w-md">
@@ -70,6 +171,12 @@ export default function Login() {
             />
           </div>
 
+          <div className="text-right">
+            <Link href="/forgot-password" className="text-sm text-blue-600 hover:underline">
+              Forgot password?
+            </Link>
+          </div>
+
           <button
             type="submit"
             disabled={loading}
@@ -79,6 +186,27 @@ export default function Login() {
           </button>
         </form>
 
+        <button
+          type="button"
+          onClick={handlePasskey}
+          disabled={loading}
+          className="w-full mt-3 bg-gray-100 text-gray-800 py-2 rounded-lg font-semibold hover:bg-gray-200 transition disabled:opacity-50 disabled:cursor-not-allowed"
+        >
+          Login with a passkey
+        </button>
+
+        {providers.map((provider) => (
+          <button
+            key={provider.id}
+            type="button"
+            onClick={() => handleSSO(provider.id)}
+            disabled={loading}
+            className="w-full mt-3 bg-gray-100 text-gray-800 py-2 rounded-lg font-semibold hover:bg-gray-200 transition disabled:opacity-50 disabled:cursor-not-allowed"
+          >
+            Login with {provider.name}
+          </button>
+        ))}
+
         <div className="mt-6 text-center">
           <p className="text-gray-600 mb-2">Test credentials:</p>
           <p className="text-sm text-gray-500">Email: john@example.com</p>
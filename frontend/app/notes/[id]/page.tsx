@@ -3,13 +3,17 @@
 import { useEffect, useState } from 'react'
 import { useRouter, useParams } from 'next/navigation'
 import Link from 'next/link'
-import { getNote, deleteNote, uploadImage, isAuthenticated } from '@/lib/api'
+import { getNote, deleteNote, uploadImage, isAuthenticated, fileUrl } from '@/lib/api'
 
 interface Note {
   id: number
   title: string
   content: string
-  image_url?: string
+  images?: {
+    original: string
+    medium: string
+    thumbnail: string
+  }
   created_at: string
 }
 
@@ -128,10 +132,10 @@ export default function NoteDetail() {
             {new Date(note.created_at).toLocaleTimeString()}
           </p>
 
-          {note.image_url && (
+          {note.images && (
             <div className="mb-6">
               <img
-                src={`http://localhost:8080${note.image_url}`}
+                src={fileUrl(note.images.medium)}
                 alt={note.title}
                 className="w-full max-h-96 object-contain rounded-lg"
               />
@@ -3,13 +3,17 @@
 import { useEffect, useState } from 'react'
 import { useRouter } from 'next/navigation'
 import Link from 'next/link'
-import { getNotes, logout, isAuthenticated, getCurrentUser } from '@/lib/api'
+import { getNotes, logout, isAuthenticated, getCurrentUser, fileUrl } from '@/lib/api'
 
 interface Note {
   id: number
   title: string
   content: string
-  image_url?: string
+  images?: {
+    original: string
+    medium: string
+    thumbnail: string
+  }
   created_at: string
 }
 
@@ -105,10 +109,10 @@ export default function Notes() {
                   {note.title}
                 </h3>
                 <p className="text-gray-600 mb-4 line-clamp-3">{note.content}</p>
-                {note.image_url && (
+                {note.images && (
                   <div className="mb-4">
                     <img
-                      src={`http://localhost:8080${note.image_url}`}
+                      src={fileUrl(note.images.thumbnail)}
                       alt={note.title}
                       className="w-full h-32 object-cover rounded"
                     />
@@ -0,0 +1,119 @@
+'use client'
+
+import { Suspense, useEffect, useRef, useState } from 'react'
+import { useRouter, useSearchParams } from 'next/navigation'
+import Link from 'next/link'
+import { finishOIDCLogin, verifyTwoFactor } from '@/lib/api'
+
+function SSOCallback() {
+  const router = useRouter()
+  const searchParams = useSearchParams()
+  const [error, setError] = useState('')
+  const [loading, setLoading] = useState(false)
+  const [challengeToken, setChallengeToken] = useState('')
+  const [code, setCode] = useState('')
+  const started = useRef(false)
+
+  useEffect(() => {
+    // The login state can only be used once
+    if (started.current) return
+    started.current = true
+
+    const providerError = searchParams.get('error')
+    const state = searchParams.get('state') || ''
+    const authCode = searchParams.get('code') || ''
+    if (providerError || !state || !authCode) {
+      setError(searchParams.get('error_description') || 'Single sign-on was cancelled or failed.')
+      return
+    }
+
+    finishOIDCLogin(state, authCode)
+      .then((data) => {
+        if (data.two_factor_required) {
+          setChallengeToken(data.challenge_token)
+          return
+        }
+        router.push('/notes')
+      })
+      .catch((err: any) => setError(err.message || 'Single sign-on failed'))
+  }, [router, searchParams])
+
+  const handleVerify = async (e: React.FormEvent) => {
+    e.preventDefault()
+    setError('')
+    setLoading(true)
+
+    try {
+      await verifyTwoFactor(challengeToken, code)
+      router.push('/notes')
+    } catch (err: any) {
+      setError(err.message || 'Verification failed')
+    } finally {
+      setLoading(false)
+    }
+  }
+
+  return (
+    <div className="min-h-screen flex items-center justify-center bg-gradient-to-br from-blue-500 to-purple-600 p-4">
+      <div className="bg-white rounded-lg shadow-xl p-8 w-full max-w-md">
+        <h1 className="text-3xl font-bold text-center mb-6">
+          {challengeToken ? 'Two-Factor Authentication' : 'Single Sign-On'}
+        </h1>
+
+        {!challengeToken && !error && (
+          <p className="text-gray-600 text-center">Logging you in...</p>
+        )}
+
+        {error && (
+          <div className="bg-red-50 border border-red-200 text-red-700 px-4 py-3 rounded mb-4">
+            {error}
+          </div>
+        )}
+
+        {challengeToken && (
+          <form onSubmit={handleVerify} className="space-y-4">
+            <div>
+              <label className="block text-sm font-medium text-gray-700 mb-1">
+                Code from your authenticator app, or a recovery code
+              </label>
+              <input
+                type="text"
+                required
+                autoFocus
+                autoComplete="one-time-code"
+                value={code}
+                onChange={(e) => setCode(e.target.value)}
+                className="w-full px-4 py-2 border border-gray-300 rounded-lg focus:ring-2 focus:ring-blue-500 focus:border-transparent"
+                placeholder="123456"
+              />
+            </div>
+
+            <button
+              type="submit"
+              disabled={loading}
+              className="w-full bg-blue-600 text-white py-2 rounded-lg font-semibold hover:bg-blue-700 transition disabled:opacity-50 disabled:cursor-not-allowed"
+            >
+              {loading ? 'Verifying...' : 'Verify'}
+            </button>
+          </form>
+        )}
+
+        {error && !challengeToken && (
+          <p className="text-center mt-6 text-gray-600">
+            <Link href="/login" className="text-blue-600 hover:underline font-semibold">
+              Back to login
+            </Link>
+          </p>
+        )}
+      </div>
+    </div>
+  )
+}
+
+export default function SSOCallbackPage() {
+  return (
+    <Suspense>
+      <SSOCallback />
+    </Suspense>
+  )
+}
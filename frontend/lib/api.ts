@@ -10,6 +10,96 @@ const getToken = (): string | null => {
   return null;
 };
 
+// Get refresh token from localStorage
+const getRefreshToken = (): string | null => {
+  if (typeof window !== 'undefined') {
+    return localStorage.getItem('refreshToken');
+  }
+  return null;
+};
+
+// Store the tokens returned by login or refresh
+const storeTokens = (data: { token?: string; refresh_token?: string; user?: unknown }) => {
+  if (data.token) {
+    localStorage.setItem('token', data.token);
+  }
+  if (data.refresh_token) {
+    localStorage.setItem('refreshToken', data.refresh_token);
+  }
+  if (data.user) {
+    localStorage.setItem('user', JSON.stringify(data.user));
+  }
+};
+
+// Exchange the refresh token for a new access token. Concurrent callers share
+// one request, since each refresh token can only be used once. Tabs share the
+// stored tokens too, so the exchange holds a lock across tabs and is skipped
+// when another tab has replaced the access token that was rejected.
+let refreshing: Promise<boolean> | null = null;
+
+const refreshAccessToken = (rejectedToken: string): Promise<boolean> => {
+  if (!refreshing) {
+    const run = () => rotateTokens(rejectedToken);
+    refreshing = ('locks' in navigator ? navigator.locks.request('notes-token-refresh', run) : run()).finally(() => {
+      refreshing = null;
+    });
+  }
+  return refreshing;
+};
+
+const rotateTokens = async (rejectedToken: string): Promise<boolean> => {
+  if (getToken() !== rejectedToken) return true;
+
+  const refreshToken = getRefreshToken();
+  if (!refreshToken) return false;
+
+  const response = await fetch(`${API_URL}/api/auth/refresh`, {
+    method: 'POST',
+    headers: {
+      'Content-Type': 'application/json',
+    },
+    body: JSON.stringify({ refresh_token: refreshToken }),
+  });
+
+  // Another tab refreshed a moment ago without holding the lock; give it
+  // time to store the new tokens
+  if (response.status === 409) {
+    await new Promise((resolve) => setTimeout(resolve, 1000));
+    if (getRefreshToken() !== refreshToken) return true;
+  }
+
+  if (!response.ok) {
+    clearSession();
+    return false;
+  }
+
+  storeTokens(await response.json());
+  return true;
+};
+
+// Fetch an authenticated endpoint, refreshing the access token once if it has expired
+const authFetch = async (path: string, init: RequestInit = {}): Promise<Response> => {
+  const send = () => {
+    const token = getToken();
+    if (!token) throw new Error('No token found');
+
+    return fetch(`${API_URL}${path}`, {
+      ...init,
+      headers: {
+        ...init.headers,
+        'Authorization': `Bearer ${token}`,
+      },
+    });
+  };
+
+  const token = getToken();
+  const response = await send();
+  if (response.status === 401 && token && (await refreshAccessToken(token))) {
+    return send();
+  }
+  return response;
+};
+
 // Register user
 export const register = async (name: string, email: string, password: string) => {
   const response = await fetch(`${API_URL}/api/auth/register`, {
@@ -45,50 +135,434 @@ export const login = async (email: string, password: string) => {
 
   const data = await response.json();
   
-  // Store token in localStorage
-  if (data.token) {
-    localStorage.setItem('token', data.token);
-    localStorage.setItem('user', JSON.stringify(data.user));
-  }
+  // Store tokens in localStorage
+  storeTokens(data);
 
   return data;
 };
 
-// Logout user
-export const logout = () => {
+// Request a password reset link by email
+export const forgotPassword = async (email: string) => {
+  const response = await fetch(`${API_URL}/api/auth/forgot-password`, {
+    method: 'POST',
+    headers: {
+      'Content-Type': 'application/json',
+    },
+    body: JSON.stringify({ email }),
+  });
+
+  if (!response.ok) {
+    const error = await response.json();
+    throw new Error(error.error || 'Failed to request password reset');
+  }
+
+  return response.json();
+};
+
+// Set a new password with the token from the reset link
+export const resetPassword = async (token: string, password: string) => {
+  const response = await fetch(`${API_URL}/api/auth/reset-password`, {
+    method: 'POST',
+    headers: {
+      'Content-Type': 'application/json',
+    },
+    body: JSON.stringify({ token, password }),
+  });
+
+  if (!response.ok) {
+    const error = await response.json();
+    throw new Error(error.error || 'Failed to reset password');
+  }
+
+  return response.json();
+};
+
+// Verify the email address with the token from the verification link
+export const verifyEmail = async (token: string) => {
+  const response = await fetch(`${API_URL}/api/auth/verify-email`, {
+    method: 'POST',
+    headers: {
+      'Content-Type': 'application/json',
+    },
+    body: JSON.stringify({ token }),
+  });
+
+  if (!response.ok) {
+    const error = await response.json();
+    throw new Error(error.error || 'Failed to verify email');
+  }
+
+  return response.json();
+};
+
+// Send a new verification link to the current user
+export const resendVerification = async () => {
+  const response = await authFetch('/api/auth/resend-verification', {
+    method: 'POST',
+  });
+
+  if (!response.ok) {
+    const error = await response.json();
+    throw new Error(error.error || 'Failed to send verification email');
+  }
+
+  return response.json();
+};
+
+// Change the current user's password; other devices are logged out
+export const changePassword = async (currentPassword: string, newPassword: string) => {
+  const response = await authFetch('/api/me/password', {
+    method: 'PUT',
+    headers: {
+      'Content-Type': 'application/json',
+    },
+    body: JSON.stringify({ current_password: currentPassword, new_password: newPassword }),
+  });
+
+  if (!response.ok) {
+    const error = await response.json();
+    throw new Error(error.error || 'Failed to change password');
+  }
+
+  return response.json();
+};
+
+// Request an email change; a confirmation link is sent to the new address
+export const changeEmail = async (newEmail: string, password: string) => {
+  const response = await authFetch('/api/me/email', {
+    method: 'PUT',
+    headers: {
+      'Content-Type': 'application/json',
+    },
+    body: JSON.stringify({ new_email: newEmail, password }),
+  });
+
+  if (!response.ok) {
+    const error = await response.json();
+    throw new Error(error.error || 'Failed to change email');
+  }
+
+  return response.json();
+};
+
+// Confirm an email change with the token from the confirmation link
+export const confirmEmailChange = async (token: string) => {
+  const response = await fetch(`${API_URL}/api/auth/confirm-email`, {
+    method: 'POST',
+    headers: {
+      'Content-Type': 'application/json',
+    },
+    body: JSON.stringify({ token }),
+  });
+
+  if (!response.ok) {
+    const error = await response.json();
+    throw new Error(error.error || 'Failed to confirm email change');
+  }
+
+  return response.json();
+};
+
+// Forget the stored tokens and user
+const clearSession = () => {
   localStorage.removeItem('token');
+  localStorage.removeItem('refreshToken');
   localStorage.removeItem('user');
 };
 
-// Get all notes
-export const getNotes = async () => {
-  const token = getToken();
-  if (!token) throw new Error('No token found');
+// Complete a login that requires two-factor authentication
+export const verifyTwoFactor = async (challengeToken: string, code: string) => {
+  const response = await fetch(`${API_URL}/api/auth/2fa/verify`, {
+    method: 'POST',
+    headers: {
+      'Content-Type': 'application/json',
+    },
+    body: JSON.stringify({ challenge_token: challengeToken, code }),
+  });
+
+  if (!response.ok) {
+    const error = await response.json();
+    throw new Error(error.error || 'Verification failed');
+  }
 
-  const response = await fetch(`${API_URL}/api/notes`, {
+  const data = await response.json();
+  storeTokens(data);
+
+  return data;
+};
+
+// List the single sign-on identity providers
+export const getOIDCProviders = async (): Promise<{ id: string; name: string }[]> => {
+  const response = await fetch(`${API_URL}/api/auth/oidc/providers`);
+
+  if (!response.ok) {
+    return [];
+  }
+
+  const data = await response.json();
+  return data.providers;
+};
+
+// Start a single sign-on login by sending the browser to the identity provider.
+// The state is kept in this tab to check that the callback belongs to it.
+export const beginOIDCLogin = async (provider: string) => {
+  const response = await fetch(`${API_URL}/api/auth/oidc/${encodeURIComponent(provider)}/begin`, {
+    method: 'POST',
+  });
+
+  if (!response.ok) {
+    const error = await response.json();
+    throw new Error(error.error || 'Single sign-on failed');
+  }
+
+  const data = await response.json();
+  sessionStorage.setItem('oidcState', data.state);
+  sessionStorage.setItem('oidcBrowserSecret', data.browser_secret);
+  window.location.assign(data.authorization_url);
+};
+
+// Complete a single sign-on login with the parameters the provider redirected back with
+export const finishOIDCLogin = async (state: string, code: string) => {
+  const expectedState = sessionStorage.getItem('oidcState');
+  const browserSecret = sessionStorage.getItem('oidcBrowserSecret');
+  sessionStorage.removeItem('oidcState');
+  sessionStorage.removeItem('oidcBrowserSecret');
+  if (!expectedState || expectedState !== state || !browserSecret) {
+    throw new Error('Single sign-on login was not started from this browser, please try again');
+  }
+
+  const response = await fetch(`${API_URL}/api/auth/oidc/callback`, {
+    method: 'POST',
     headers: {
-      'Authorization': `Bearer ${token}`,
+      'Content-Type': 'application/json',
     },
+    body: JSON.stringify({ state, code, browser_secret: browserSecret }),
   });
 
   if (!response.ok) {
-    throw new Error('Failed to fetch notes');
+    const error = await response.json();
+    throw new Error(error.error || 'Single sign-on failed');
+  }
+
+  const data = await response.json();
+  storeTokens(data);
+
+  return data;
+};
+
+// base64url <-> ArrayBuffer conversions for the WebAuthn API
+const fromBase64url = (value: string): ArrayBuffer => {
+  const base64 = value.replace(/-/g, '+').replace(/_/g, '/');
+  const binary = atob(base64 + '='.repeat((4 - (base64.length % 4)) % 4));
+  return Uint8Array.from(binary, (c) => c.charCodeAt(0)).buffer;
+};
+
+const toBase64url = (buffer: ArrayBuffer | null): string => {
+  if (!buffer) return '';
+  const binary = String.fromCharCode(...Array.from(new Uint8Array(buffer)));
+  return btoa(binary).replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '');
+};
+
+// Log in with a passkey stored on this device or a security key
+export const loginWithPasskey = async () => {
+  const begin = await fetch(`${API_URL}/api/auth/passkeys/login/begin`, { method: 'POST' });
+  if (!begin.ok) {
+    const error = await begin.json();
+    throw new Error(error.error || 'Passkey login failed');
+  }
+  const { publicKey } = await begin.json();
+
+  const credential = (await navigator.credentials.get({
+    publicKey: {
+      ...publicKey,
+      challenge: fromBase64url(publicKey.challenge),
+      allowCredentials: [],
+    },
+  })) as PublicKeyCredential | null;
+  if (!credential) throw new Error('Passkey login was cancelled');
+
+  const assertion = credential.response as AuthenticatorAssertionResponse;
+  const response = await fetch(`${API_URL}/api/auth/passkeys/login/finish`, {
+    method: 'POST',
+    headers: {
+      'Content-Type': 'application/json',
+    },
+    body: JSON.stringify({
+      credential: {
+        id: credential.id,
+        rawId: toBase64url(credential.rawId),
+        type: credential.type,
+        response: {
+          clientDataJSON: toBase64url(assertion.clientDataJSON),
+          authenticatorData: toBase64url(assertion.authenticatorData),
+          signature: toBase64url(assertion.signature),
+          userHandle: toBase64url(assertion.userHandle),
+        },
+      },
+    }),
+  });
+
+  if (!response.ok) {
+    const error = await response.json();
+    throw new Error(error.error || 'Passkey login failed');
+  }
+
+  const data = await response.json();
+  storeTokens(data);
+
+  return data;
+};
+
+// Register a new passkey for the current user
+export const registerPasskey = async (name: string) => {
+  const begin = await authFetch('/api/me/passkeys/register/begin', { method: 'POST' });
+  if (!begin.ok) {
+    const error = await begin.json();
+    throw new Error(error.error || 'Failed to register passkey');
+  }
+  const { publicKey } = await begin.json();
+
+  const credential = (await navigator.credentials.create({
+    publicKey: {
+      ...publicKey,
+      challenge: fromBase64url(publicKey.challenge),
+      user: { ...publicKey.user, id: fromBase64url(publicKey.user.id) },
+      excludeCredentials: publicKey.excludeCredentials.map((c: { type: 'public-key'; id: string }) => ({
+        ...c,
+        id: fromBase64url(c.id),
+      })),
+    },
+  })) as PublicKeyCredential | null;
+  if (!credential) throw new Error('Passkey registration was cancelled');
+
+  const attestation = credential.response as AuthenticatorAttestationResponse;
+  const response = await authFetch('/api/me/passkeys/register/finish', {
+    method: 'POST',
+    headers: {
+      'Content-Type': 'application/json',
+    },
+    body: JSON.stringify({
+      name,
+      credential: {
+        id: credential.id,
+        rawId: toBase64url(credential.rawId),
+        type: credential.type,
+        response: {
+          clientDataJSON: toBase64url(attestation.clientDataJSON),
+          attestationObject: toBase64url(attestation.attestationObject),
+        },
+      },
+    }),
+  });
+
+  if (!response.ok) {
+    const error = await response.json();
+    throw new Error(error.error || 'Failed to register passkey');
   }
 
   return response.json();
 };
 
-// Get single note
-export const getNote = async (id: string) => {
+// Logout user, revoking the tokens on the server
+export const logout = () => {
   const token = getToken();
-  if (!token) throw new Error('No token found');
+  const refreshToken = getRefreshToken();
+  clearSession();
+
+  if (token) {
+    fetch(`${API_URL}/api/auth/logout`, {
+      method: 'POST',
+      headers: {
+        'Content-Type': 'application/json',
+        'Authorization': `Bearer ${token}`,
+      },
+      body: JSON.stringify({ refresh_token: refreshToken }),
+      keepalive: true,
+    }).catch(() => {});
+  }
+};
+
+// Get the devices the user is logged in on
+export const getSessions = async () => {
+  const response = await authFetch('/api/auth/sessions');
+
+  if (!response.ok) {
+    throw new Error('Failed to fetch sessions');
+  }
+
+  return response.json();
+};
+
+// End a session on another device
+export const deleteSession = async (id: string) => {
+  const response = await authFetch(`/api/auth/sessions/${id}`, {
+    method: 'DELETE',
+  });
+
+  if (!response.ok) {
+    throw new Error('Failed to end session');
+  }
+
+  return response.json();
+};
 
-  const response = await fetch(`${API_URL}/api/notes/${id}`, {
+// List the user's personal access tokens
+export const getPersonalAccessTokens = async () => {
+  const response = await authFetch('/api/me/tokens');
+
+  if (!response.ok) {
+    throw new Error('Failed to fetch tokens');
+  }
+
+  return response.json();
+};
+
+// Create a personal access token; the token is only returned once
+export const createPersonalAccessToken = async (name: string, scopes: string[], expiresAt?: string) => {
+  const response = await authFetch('/api/me/tokens', {
+    method: 'POST',
     headers: {
-      'Authorization': `Bearer ${token}`,
+      'Content-Type': 'application/json',
     },
+    body: JSON.stringify({ name, scopes, expires_at: expiresAt || null }),
+  });
+
+  if (!response.ok) {
+    const error = await response.json();
+    throw new Error(error.error || 'Failed to create token');
+  }
+
+  return response.json();
+};
+
+// Revoke a personal access token
+export const deletePersonalAccessToken = async (id: number) => {
+  const response = await authFetch(`/api/me/tokens/${id}`, {
+    method: 'DELETE',
   });
 
+  if (!response.ok) {
+    throw new Error('Failed to delete token');
+  }
+
+  return response.json();
+};
+
+// Get all notes
+export const getNotes = async () => {
+  const response = await authFetch('/api/notes');
+
+  if (!response.ok) {
+    throw new Error('Failed to fetch notes');
+  }
+
+  return response.json();
+};
+
+// Get single note
+export const getNote = async (id: string) => {
+  const response = await authFetch(`/api/notes/${id}`);
+
   if (!response.ok) {
     throw new Error('Failed to fetch note');
   }
@@ -98,14 +572,10 @@ export const getNote = async (id: string) => {
 
 // Create note
 export const createNote = async (title: string, content: string) => {
-  const token = getToken();
-  if (!token) throw new Error('No token found');
-
-  const response = await fetch(`${API_URL}/api/notes`, {
+  const response = await authFetch('/api/notes', {
     method: 'POST',
     headers: {
       'Content-Type': 'application/json',
-      'Authorization': `Bearer ${token}`,
     },
     body: JSON.stringify({ title, content }),
   });
@@ -120,14 +590,10 @@ export const createNote = async (title: string, content: string) => {
 
 // Update note
 export const updateNote = async (id: string, title: string, content: string) => {
-  const token = getToken();
-  if (!token) throw new Error('No token found');
-
-  const response = await fetch(`${API_URL}/api/notes/${id}`, {
+  const response = await authFetch(`/api/notes/${id}`, {
     method: 'PUT',
     headers: {
       'Content-Type': 'application/json',
-      'Authorization': `Bearer ${token}`,
     },
     body: JSON.stringify({ title, content }),
   });
@@ -141,14 +607,8 @@ export const updateNote = async (id: string, title: string, content: string) =>
 
 // Delete note
 export const deleteNote = async (id: string) => {
-  const token = getToken();
-  if (!token) throw new Error('No token found');
-
-  const response = await fetch(`${API_URL}/api/notes/${id}`, {
+  const response = await authFetch(`/api/notes/${id}`, {
     method: 'DELETE',
-    headers: {
-      'Authorization': `Bearer ${token}`,
-    },
   });
 
   if (!response.ok) {
@@ -160,17 +620,11 @@ export const deleteNote = async (id: string) => {
 
 // Upload image for note
 export const uploadImage = async (noteId: string, file: File) => {
-  const token = getToken();
-  if (!token) throw new Error('No token found');
-
   const formData = new FormData();
   formData.append('image', file);
 
-  const response = await fetch(`${API_URL}/api/notes/${noteId}/upload`, {
+  const response = await authFetch(`/api/notes/${noteId}/upload`, {
     method: 'POST',
-    headers: {
-      'Authorization': `Bearer ${token}`,
-    },
     body: formData,
   });
 
@@ -182,6 +636,11 @@ export const uploadImage = async (noteId: string, file: File) => {
   return response.json();
 };
 
+// Resolve an uploaded file URL returned by the API (relative for local storage, absolute for S3)
+export const fileUrl = (url: string): string => {
+  return /^https?:\/\//.test(url) ? url : `${API_URL}${url}`;
+};
+
 // Check if user is authenticated
 export const isAuthenticated = (): boolean => {
   return getToken() !== null;